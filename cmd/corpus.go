@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/ttacon/chalk"
+)
+
+type corpusCommand struct {
+	Corpus string `long:"corpus" description:"Path of the corpus of labeled annotations" default:"internal/services/testdata/corpus"`
+}
+
+type corpusAddCommand struct {
+	Artist string `long:"artist" description:"Expected artist (defaults to the artists of the matched track)"`
+	Name   string `long:"name" description:"Name of the fixture (defaults to the name of the image)"`
+	Record bool   `long:"record" description:"Match the fixture with the music provider and record the responses for eval"`
+	Source string `long:"source" description:"App the screenshot was taken from (defaults to the app detected from the text)"`
+	Title  string `long:"title" description:"Expected title (defaults to the name of the matched track)"`
+	corpus *corpusCommand
+}
+
+type corpusReportCommand struct {
+	corpus *corpusCommand
+}
+
+func init() {
+	corpus := &corpusCommand{}
+
+	cmd, err := parser.AddCommand(
+		"corpus",
+		"Maintain the corpus of labeled annotations",
+		"Captures fixtures for the corpus the screenshot parser is tested against and reports the accuracy of the parser across it",
+		corpus)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := cmd.AddCommand(
+		"add",
+		"Add a screenshot to the corpus",
+		"Captures a fixture from the text detected in the screenshot by a previous scan, labeled with the matched track unless --artist and --title are supplied",
+		&corpusAddCommand{corpus: corpus}); err != nil {
+		panic(err)
+	}
+
+	if _, err := cmd.AddCommand(
+		"report",
+		"Report the accuracy of the parser",
+		"Parses each fixture in the corpus and prints the portion parsed correctly for each source",
+		&corpusReportCommand{corpus: corpus}); err != nil {
+		panic(err)
+	}
+}
+
+// Execute adds the screenshot to the corpus
+func (c *corpusAddCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("supply the path of a single screenshot to add")
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	f, err := a.newCorpusService(c.corpus.Corpus).Add(args[0], models.Fixture{
+		Artist: c.Artist,
+		Name:   c.Name,
+		Source: c.Source,
+		Title:  c.Title,
+	}, c.Record)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Added fixture %s%s%s (%s - %s, %s) to %s\n",
+		chalk.Blue,
+		f.Name,
+		chalk.Reset,
+		f.Artist,
+		f.Title,
+		f.Source,
+		c.corpus.Corpus)
+
+	return nil
+}
+
+// Execute prints the accuracy of the parser across the corpus
+func (c *corpusReportCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	report, err := a.newCorpusService(c.corpus.Corpus).Report()
+	if err != nil {
+		return err
+	}
+
+	for _, sa := range report.Sources {
+		printAccuracy(sa.Source, sa)
+
+		for _, name := range sa.Failures {
+			fmt.Println(chalk.Red, "  failed:", chalk.Reset, name)
+		}
+	}
+
+	printAccuracy("Total", report.Total)
+
+	return nil
+}
+
+func printAccuracy(label string, sa models.SourceAccuracy) {
+	fmt.Printf(
+		" %s%-24s%s %3d/%-3d %6.1f%%\n",
+		chalk.Blue,
+		label,
+		chalk.Reset,
+		sa.Correct,
+		sa.Fixtures,
+		sa.Accuracy()*100)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "github.com/brozeph/song-finder/internal/models"
+
+// discoveryOptions are the command line options controlling which
+// images the scan and run commands discover as screenshots
+type discoveryOptions struct {
+	Classifier     string   `long:"classifier" description:"Classifier deciding which images are music screenshots to detect text in, labels uses Vision label detection" choice:"local" choice:"labels" choice:"none" default:"local"`
+	Exclude        []string `long:"exclude" description:"Glob pattern of files or directories to skip, matched against the name or the path relative to --path (may be repeated)"`
+	FollowSymlinks bool     `long:"follow-symlinks" description:"Follow symbolic links to files and directories"`
+	Include        []string `long:"include" description:"Glob pattern of the files to scan, matched against the name or the path relative to --path (may be repeated)"`
+	MaxDepth       int      `long:"max-depth" description:"Maximum depth of directories to scan, 1 scans only --path (defaults to no limit)"`
+}
+
+// options returns the discovery options with the capture range
+// supplied with --since and --until
+func (o discoveryOptions) options() models.DiscoveryOptions {
+	return models.DiscoveryOptions{
+		Classifier:     o.Classifier,
+		Exclude:        o.Exclude,
+		FollowSymlinks: o.FollowSymlinks,
+		Include:        o.Include,
+		MaxDepth:       o.MaxDepth,
+		Since:          captureSince,
+		Until:          captureUntil,
+	}
+}
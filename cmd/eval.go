@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/ttacon/chalk"
+)
+
+type evalCommand struct {
+	Corpus   string `long:"corpus" description:"Path of the corpus of labeled annotations" default:"internal/services/testdata/corpus"`
+	Failures bool   `long:"failures" description:"List the fixtures each stage found no song or the wrong song in"`
+}
+
+func init() {
+	parser.AddCommand(
+		"eval",
+		"Evaluate the parser and matcher",
+		"Parses and matches each fixture in the corpus, searching the responses recorded with the fixture, and prints the precision and recall of parsing and searching for each source",
+		&evalCommand{})
+}
+
+// Execute prints the precision and recall of each stage across the
+// corpus
+func (c *evalCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	report, err := a.newCorpusService(c.Corpus).Evaluate()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		" %-24s %-7s %9s %9s %9s\n",
+		"Source",
+		"Stage",
+		"Correct",
+		"Precision",
+		"Recall")
+
+	for _, se := range report.Sources {
+		c.printEvaluation(se.Source, se)
+	}
+
+	c.printEvaluation("Total", report.Total)
+
+	return nil
+}
+
+func (c *evalCommand) printEvaluation(label string, se models.SourceEvaluation) {
+	for _, stage := range []struct {
+		name string
+		sa   models.StageAccuracy
+	}{
+		{"parse", se.Parse},
+		{"search", se.Search},
+	} {
+		fmt.Printf(
+			" %s%-24s%s %-7s %4d/%-4d %8.1f%% %8.1f%%\n",
+			chalk.Blue,
+			label,
+			chalk.Reset,
+			stage.name,
+			stage.sa.Correct,
+			stage.sa.Expected,
+			stage.sa.Precision()*100,
+			stage.sa.Recall()*100)
+
+		// the source is only labeled on its first stage
+		label = ""
+
+		if !c.Failures {
+			continue
+		}
+
+		for _, name := range stage.sa.Failures {
+			fmt.Println(chalk.Red, "  failed:", chalk.Reset, name)
+		}
+	}
+}
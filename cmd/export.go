@@ -0,0 +1,28 @@
+package main
+
+const defaultExportFormat = "csv"
+
+type exportCommand struct{}
+
+func init() {
+	parser.AddCommand(
+		"export",
+		"Export screenshot results",
+		"Writes the results for each screenshot in state in the format supplied with --output (csv by default)",
+		&exportCommand{})
+}
+
+// Execute writes the results from state
+func (c *exportCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	state, err := a.screenshotService.State()
+	if err != nil {
+		return err
+	}
+
+	return writeResults(a, state, defaultExportFormat)
+}
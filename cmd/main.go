@@ -1,79 +1,174 @@
 // Program that reads image files from a specified file path,
 // then uses Google's ML cloud API for reading text, and
-// finally queries Spotify to find matches and create a
-// playlist
+// finally queries a music provider (Spotify by default) to
+// find matches and create a playlist
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/brozeph/song-finder/internal/interfaces"
 	"github.com/brozeph/song-finder/internal/repositories"
 	"github.com/brozeph/song-finder/internal/services"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/ttacon/chalk"
 )
 
-const stateFileName = "song-finder.state.json"
+const (
+	providerAppleMusic = "applemusic"
+	providerSpotify    = "spotify"
+	providerYouTube    = "youtube"
+	stateFileName      = "song-finder.state.json"
+	youTubeTokenName   = "song-finder.youtube.token.json"
+)
 
 type cmdlineOptions struct {
-	ImageFilePath string `short:"p" long:"path" description:"Path to image files" required:"true"`
-	PlaylistName  string `short:"n" long:"playlist" description:"Name of Spotify playlist to create" required:"true"`
+	Artists       string   `long:"artists" description:"Path of a file listing known artists, one per line, which songs are corrected against in addition to the artists of matched tracks"`
+	Language      []string `long:"language" description:"Language expected in the screenshots as a BCP-47 code (e.g. es, de, ja) to hint text detection (may be repeated)"`
+	Output        string   `short:"o" long:"output" description:"Format for writing results" choice:"csv" choice:"import-csv" choice:"json" choice:"jsonl" choice:"m3u" choice:"musicbrainz" choice:"text" choice:"xspf"`
+	MusicBrainz   bool     `long:"musicbrainz" description:"Verify parsed songs against MusicBrainz before searching the music provider"`
+	Order         string   `long:"order" description:"Order of the screenshots in results and playlists" choice:"path" choice:"captured" default:"path"`
+	OutputFile    string   `long:"output-file" description:"Path of the file to write results to (defaults to stdout)"`
+	Provider      string   `short:"m" long:"provider" description:"Music provider for finding tracks and creating playlists" choice:"spotify" choice:"applemusic" choice:"youtube" default:"spotify"`
+	Since         string   `long:"since" description:"Only include screenshots captured on or after the date (YYYY-MM-DD or RFC 3339)"`
+	StateFilePath string   `short:"s" long:"state" description:"Path to the state file shared by each command"`
+	Until         string   `long:"until" description:"Only include screenshots captured on or before the date (YYYY-MM-DD or RFC 3339)"`
+	Verbose       bool     `short:"v" long:"verbose" description:"Log debug output"`
+}
+
+var (
+	options cmdlineOptions
+	parser  = flags.NewParser(&options, flags.Default)
+)
+
+// app contains the scaffolded repositories and services used by each
+// command
+type app struct {
+	exportService        interfaces.IExportService
+	musicProvider        interfaces.IMusicProvider
+	playlistService      interfaces.IPlaylistService
+	reviewService        interfaces.IReviewService
+	screenshotRepository interfaces.IScreenshotRepository
+	screenshotService    interfaces.IScreenshotService
+	stateRepository      interfaces.IStateRepository
 }
 
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
-	var (
-		options cmdlineOptions
-		parser  = flags.NewParser(&options, flags.Default)
-	)
+	parser.CommandHandler = func(cmd flags.Commander, args []string) error {
+		if options.Verbose {
+			zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		}
+
+		if err := parseCaptureRange(); err != nil {
+			return err
+		}
 
-	// parse command line arguments
+		return cmd.Execute(args)
+	}
+
+	// parse command line arguments and execute the command
 	if _, err := parser.Parse(); err != nil {
+		if fe, ok := err.(*flags.Error); ok && fe.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+
 		log.Error().Stack().Err(err).Msg("")
 		os.Exit(1)
 	}
+}
 
-	// get working directory
-	pwd, err := os.Getwd()
-	if err != nil {
-		log.Error().Stack().Err(err).Msg("")
-		os.Exit(1)
+// newApp scaffolds up the repositories and services
+func newApp() (*app, error) {
+	statePath := options.StateFilePath
+
+	if statePath == "" {
+		// get working directory
+		pwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+
+		statePath = filepath.Join(pwd, stateFileName)
+	}
+
+	screenshotRepository := repositories.NewScreenshotRepository(repositories.ScreenshotOptions{
+		LanguageHints: options.Language,
+	})
+	musicProvider := newMusicProvider(filepath.Dir(statePath))
+	stateRepository := repositories.NewStateRepository(statePath)
+
+	var artistRepository *interfaces.IArtistRepository
+	if options.Artists != "" {
+		adr := repositories.NewArtistRepository(options.Artists)
+		artistRepository = &adr
+	}
+
+	var metadataRepository *interfaces.IMetadataRepository
+	if options.MusicBrainz {
+		mbr := repositories.NewMusicBrainzRepository(repositories.MusicBrainzOptions{})
+		metadataRepository = &mbr
 	}
 
-	// scaffold up the app
-	screenshotRepository := repositories.NewScreenshotRepository()
-	spotifyRepository := repositories.NewSpotifyRepository()
-	stateRepository := repositories.NewStateRepository(filepath.Join(pwd, stateFileName))
 	screenshotService := services.NewScreenshotService(
 		&screenshotRepository,
-		&spotifyRepository,
-		&stateRepository)
+		&musicProvider,
+		&stateRepository,
+		metadataRepository,
+		artistRepository)
 
-	// find all of the image files
-	state, err := screenshotService.Begin(options.ImageFilePath)
+	return &app{
+		exportService: services.NewExportService(),
+		musicProvider: musicProvider,
+		playlistService: services.NewPlaylistService(
+			&musicProvider,
+			&stateRepository),
+		reviewService: services.NewReviewService(
+			&musicProvider,
+			&stateRepository),
+		screenshotRepository: screenshotRepository,
+		screenshotService:    screenshotService,
+		stateRepository:      stateRepository,
+	}, nil
+}
 
-	if err != nil {
-		panic(err)
-	}
+// newCorpusService scaffolds the corpus service for the corpus at the
+// path, which only the commands maintaining and measuring it use
+func (a *app) newCorpusService(path string) interfaces.ICorpusService {
+	corpusRepository := repositories.NewCorpusRepository(path)
+
+	return services.NewCorpusService(
+		&corpusRepository,
+		&a.musicProvider,
+		&a.screenshotRepository,
+		&a.screenshotService,
+		&a.stateRepository)
+}
 
-	fmt.Println()
-	fmt.Printf(
-		"Process completed for %s%d%s files",
-		chalk.Blue,
-		len(state.Screenshots),
-		chalk.Reset)
-
-	for _, ss := range state.Screenshots {
-		fmt.Println(chalk.Blue, "File:", chalk.Reset, ss.Path)
-		fmt.Println(chalk.Red, "Song:", chalk.Reset, ss.SongSearchTerm)
-		fmt.Println(chalk.Green, "Spotify URI:", chalk.Reset, chalk.Blue, ss.SpotifyTrack.URI, chalk.Reset)
-		fmt.Println()
+// newMusicProvider returns the music provider supplied with --provider,
+// persisting any OAuth token alongside the state file
+func newMusicProvider(dir string) interfaces.IMusicProvider {
+	switch options.Provider {
+	case providerAppleMusic:
+		return repositories.NewAppleMusicRepository(repositories.AppleMusicOptions{
+			KeyID:          os.Getenv("APPLE_MUSIC_KEY_ID"),
+			PrivateKeyPath: os.Getenv("APPLE_MUSIC_PRIVATE_KEY_PATH"),
+			Storefront:     os.Getenv("APPLE_MUSIC_STOREFRONT"),
+			TeamID:         os.Getenv("APPLE_MUSIC_TEAM_ID"),
+			UserToken:      os.Getenv("APPLE_MUSIC_USER_TOKEN"),
+		})
+	case providerYouTube:
+		return repositories.NewYouTubeRepository(repositories.YouTubeOptions{
+			ClientID:     os.Getenv("YOUTUBE_CLIENT_ID"),
+			ClientSecret: os.Getenv("YOUTUBE_CLIENT_SECRET"),
+			TokenPath:    filepath.Join(dir, youTubeTokenName),
+		})
+	default:
+		return repositories.NewSpotifyRepository(repositories.SpotifyOptions{})
 	}
 }
@@ -0,0 +1,28 @@
+package main
+
+type matchCommand struct {
+	Force bool `short:"f" long:"force" description:"Search again for screenshots already matched"`
+}
+
+func init() {
+	parser.AddCommand(
+		"match",
+		"Parse detected text and search the music provider",
+		"Parses the song from the text detected for each scanned screenshot and searches the music provider for a matching track",
+		&matchCommand{})
+}
+
+// Execute parses and searches for each scanned screenshot
+func (c *matchCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	state, err := a.screenshotService.Match(c.Force)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(a, state, "")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+	"github.com/ttacon/chalk"
+)
+
+// playlistOptions are the command line options for the details of a
+// playlist created by the sync and run commands
+type playlistOptions struct {
+	Collaborative bool   `long:"collaborative" description:"Create the playlist as a collaborative playlist"`
+	Cover         bool   `long:"cover" description:"Upload a cover image created from the screenshot thumbnails"`
+	Description   string `long:"description" description:"Template for the playlist description ({{.Count}}, {{.From}} and {{.To}} are replaced)"`
+	Partition     string `long:"partition" description:"Split the matched tracks into playlists named from the playlist template ({{.Year}}, {{.Month}}, {{.MonthName}}, {{.Source}} and {{.Genre}} are replaced)" choice:"none" choice:"month" choice:"source" choice:"genre" default:"none"`
+	Public        bool   `long:"public" description:"Create the playlist as a public playlist"`
+}
+
+// options returns the playlist options with the description rendered
+// and the cover image created from the screenshots
+func (o playlistOptions) options(a *app, screenshots []*models.Screenshot) (models.PlaylistOptions, error) {
+	if o.Collaborative && o.Public {
+		return models.PlaylistOptions{}, errors.New("a collaborative playlist can not be public")
+	}
+
+	text := o.Description
+	if text == "" {
+		text = services.DefaultPlaylistDescription
+	}
+
+	description, err := a.playlistService.Description(text, screenshots)
+	if err != nil {
+		return models.PlaylistOptions{}, err
+	}
+
+	opts := models.PlaylistOptions{
+		Collaborative: o.Collaborative,
+		Description:   description,
+		Public:        o.Public,
+	}
+
+	if o.Cover {
+		if opts.Cover, err = a.playlistService.Cover(screenshots); err != nil {
+			return models.PlaylistOptions{}, err
+		}
+	}
+
+	return opts, nil
+}
+
+// sync partitions the matched screenshots in state and ensures a
+// playlist named from the name template exists for each partition
+func (o playlistOptions) sync(a *app, name string, state models.State) error {
+	partitions, err := a.playlistService.Partition(o.Partition, name, matchedScreenshots(state))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range partitions {
+		opts, err := o.options(a, p.Screenshots)
+		if err != nil {
+			return err
+		}
+
+		tracks := make([]models.Track, 0, len(p.Screenshots))
+		for _, ss := range p.Screenshots {
+			tracks = append(tracks, ss.Tracks()...)
+		}
+
+		pl, err := a.playlistService.EnsurePlaylist(p.Name, tracks, opts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf(
+			"Playlist %s%s%s synced with %s%d%s tracks %s\n",
+			chalk.Blue,
+			pl.Name,
+			chalk.Reset,
+			chalk.Blue,
+			len(tracks),
+			chalk.Reset,
+			pl.URL)
+	}
+
+	return nil
+}
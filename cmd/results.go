@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+	"github.com/ttacon/chalk"
+)
+
+// captureSince and captureUntil are the range of capture times
+// supplied with --since and --until
+var captureSince, captureUntil time.Time
+
+// filterState returns a copy of the state with only the screenshots
+// captured within the range supplied with --since and --until
+func filterState(state models.State) models.State {
+	if captureSince.IsZero() && captureUntil.IsZero() {
+		return state
+	}
+
+	filtered := state
+	filtered.Screenshots = map[string]*models.Screenshot{}
+	for sha, ss := range state.Screenshots {
+		if services.CapturedBetween(ss, captureSince, captureUntil) {
+			filtered.Screenshots[sha] = ss
+		}
+	}
+
+	return filtered
+}
+
+// matchedScreenshots returns the screenshots in state matched to
+// a track, in the order supplied with --order
+func matchedScreenshots(state models.State) []*models.Screenshot {
+	var sss []*models.Screenshot
+
+	for _, ss := range sortedScreenshots(filterState(state)) {
+		if ss.Track.URI != "" {
+			sss = append(sss, ss)
+		}
+	}
+
+	return sss
+}
+
+// parseCaptureRange parses the dates supplied with --since and --until,
+// a date without a time includes the whole of the day
+func parseCaptureRange() error {
+	var err error
+
+	if options.Since != "" {
+		if captureSince, _, err = parseDate(options.Since); err != nil {
+			return fmt.Errorf("invalid --since date %q: %v", options.Since, err)
+		}
+	}
+
+	if options.Until != "" {
+		var dateOnly bool
+		if captureUntil, dateOnly, err = parseDate(options.Until); err != nil {
+			return fmt.Errorf("invalid --until date %q: %v", options.Until, err)
+		}
+
+		if dateOnly {
+			captureUntil = captureUntil.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	return nil
+}
+
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+
+	return t, false, err
+}
+
+func printResults(state models.State) {
+	fmt.Println()
+	fmt.Printf(
+		"Process completed for %s%d%s files\n",
+		chalk.Blue,
+		len(state.Screenshots),
+		chalk.Reset)
+
+	for _, ss := range sortedScreenshots(state) {
+		fmt.Println(chalk.Blue, "File:", chalk.Reset, ss.Path)
+
+		if len(ss.Songs) == 0 {
+			fmt.Println(chalk.Red, "Song:", chalk.Reset, ss.SongSearchTerm)
+			fmt.Println(chalk.Green, "Track URI:", chalk.Reset, chalk.Blue, ss.Track.URI, chalk.Reset)
+		}
+
+		for _, song := range ss.Songs {
+			fmt.Println(chalk.Red, "Song:", chalk.Reset, song.SearchTerm)
+			fmt.Println(chalk.Green, "Track URI:", chalk.Reset, chalk.Blue, song.Track.URI, chalk.Reset)
+		}
+
+		fmt.Println()
+	}
+}
+
+// sortedScreenshots returns the screenshots in state in the order
+// supplied with --order
+func sortedScreenshots(state models.State) []*models.Screenshot {
+	sss := make([]*models.Screenshot, 0, len(state.Screenshots))
+
+	for _, ss := range state.Screenshots {
+		sss = append(sss, ss)
+	}
+
+	services.SortScreenshots(sss, options.Order)
+
+	return sss
+}
+
+// trackTitle returns the track formatted as "Artist, Artist - Name"
+func trackTitle(t models.Track) string {
+	return fmt.Sprintf("%s - %s", strings.Join(t.Artists, ", "), t.Name)
+}
+
+// writeResults writes the state in the format supplied with --output,
+// falling back to the default format, or prints the results to the
+// terminal when neither is set
+func writeResults(a *app, state models.State, defaultFormat string) error {
+	format := options.Output
+	if format == "" {
+		format = defaultFormat
+	}
+
+	state = filterState(state)
+
+	if format == "" {
+		printResults(state)
+		return nil
+	}
+
+	if options.OutputFile == "" {
+		return a.exportService.Export(os.Stdout, format, options.Order, state)
+	}
+
+	f, err := os.Create(options.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	if err := a.exportService.Export(f, format, options.Order, state); err != nil {
+		f.Close()
+		return err
+	}
+
+	// the results may not be written until the file is closed
+	return f.Close()
+}
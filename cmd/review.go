@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/ttacon/chalk"
+)
+
+type reviewCommand struct {
+	Threshold float64 `short:"t" long:"threshold" description:"Review matches with a confidence below this value" default:"0.5"`
+}
+
+func init() {
+	parser.AddCommand(
+		"review",
+		"Review screenshots with a missing or low-confidence match",
+		"Steps through each screenshot with a missing or low-confidence match to pick a track, search again or mark it as not a song",
+		&reviewCommand{})
+}
+
+// Execute steps through each screenshot needing review
+func (c *reviewCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	pending, err := a.reviewService.Pending(c.Threshold)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("No screenshots need review")
+		return nil
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+
+	for i, ss := range pending {
+		fmt.Println()
+		fmt.Printf("%sScreenshot %d of %d%s\n", chalk.Blue, i+1, len(pending), chalk.Reset)
+
+		quit, err := c.review(a, in, ss)
+		if err != nil {
+			return err
+		}
+
+		if quit {
+			break
+		}
+	}
+
+	return nil
+}
+
+// review prompts for a decision on the screenshot and returns
+// true when the review should end
+func (c *reviewCommand) review(a *app, in *bufio.Scanner, ss *models.Screenshot) (bool, error) {
+	term := ss.SongSearchTerm
+
+	candidates, err := a.reviewService.Candidates(term)
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		printReview(ss, term, candidates)
+
+		fmt.Printf(
+			"Pick a track [1-%d], (e)dit search, (x) not a song, (n)ext or (q)uit: ",
+			len(candidates))
+
+		answer, err := readLine(in)
+		if err != nil {
+			return true, err
+		}
+
+		switch strings.ToLower(answer) {
+		case "e":
+			fmt.Print("Search: ")
+			if term, err = readLine(in); err != nil {
+				return true, err
+			}
+
+			if candidates, err = a.reviewService.Candidates(term); err != nil {
+				return false, err
+			}
+		case "x":
+			return false, a.reviewService.Skip(ss.SHASum)
+		case "", "n":
+			return false, nil
+		case "q":
+			return true, nil
+		default:
+			n, err := strconv.Atoi(answer)
+			if err != nil || n < 1 || n > len(candidates) {
+				fmt.Println(chalk.Red, "Invalid choice:", chalk.Reset, answer)
+				continue
+			}
+
+			return false, a.reviewService.Resolve(ss.SHASum, term, candidates[n-1])
+		}
+	}
+}
+
+func printReview(ss *models.Screenshot, term string, candidates []models.Track) {
+	fmt.Println(chalk.Blue, "File:", chalk.Reset, ss.Path)
+	fmt.Println(chalk.Blue, "Text:", chalk.Reset)
+
+	for _, line := range strings.Split(strings.TrimSpace(ss.Text), "\n") {
+		fmt.Println("    ", line)
+	}
+
+	fmt.Println(chalk.Red, "Song:", chalk.Reset, term)
+
+	if ss.Track.URI != "" {
+		fmt.Printf(
+			" %sMatch:%s %s (%.0f%%)\n",
+			chalk.Green,
+			chalk.Reset,
+			trackTitle(ss.Track),
+			ss.Confidence*100)
+	}
+
+	if len(candidates) == 0 {
+		fmt.Println(chalk.Green, "Candidates:", chalk.Reset, "none found")
+		return
+	}
+
+	fmt.Println(chalk.Green, "Candidates:", chalk.Reset)
+	for i, t := range candidates {
+		fmt.Printf("    %d) %s %s%s%s\n", i+1, trackTitle(t), chalk.Blue, t.URI, chalk.Reset)
+	}
+}
+
+func readLine(in *bufio.Scanner) (string, error) {
+	if !in.Scan() {
+		if err := in.Err(); err != nil {
+			return "", err
+		}
+
+		return "", io.EOF
+	}
+
+	return strings.TrimSpace(in.Text()), nil
+}
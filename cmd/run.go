@@ -0,0 +1,39 @@
+package main
+
+type runCommand struct {
+	discoveryOptions
+	playlistOptions
+	ImageFilePath string `short:"p" long:"path" description:"Path to image files" required:"true"`
+	PlaylistName  string `short:"n" long:"playlist" description:"Name of the playlist to sync, a template when partitioning"`
+}
+
+func init() {
+	parser.AddCommand(
+		"run",
+		"Scan, match and sync in a single pass",
+		"Runs the scan and match commands followed by the sync command when a playlist is supplied",
+		&runCommand{})
+}
+
+// Execute runs each stage of the workflow
+func (c *runCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	state, err := a.screenshotService.Begin(c.ImageFilePath, c.discoveryOptions.options())
+	if err != nil {
+		return err
+	}
+
+	if err := writeResults(a, state, ""); err != nil {
+		return err
+	}
+
+	if c.PlaylistName == "" {
+		return nil
+	}
+
+	return c.playlistOptions.sync(a, c.PlaylistName, state)
+}
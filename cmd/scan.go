@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ttacon/chalk"
+)
+
+type scanCommand struct {
+	discoveryOptions
+	Force         bool   `short:"f" long:"force" description:"Detect text again for screenshots already scanned"`
+	ImageFilePath string `short:"p" long:"path" description:"Path to image files" required:"true"`
+}
+
+func init() {
+	parser.AddCommand(
+		"scan",
+		"Discover screenshots and detect text",
+		"Finds image files in the path and uses the Google Cloud vision API to detect the text within each",
+		&scanCommand{})
+}
+
+// Execute discovers and detects text for each screenshot
+func (c *scanCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	state, err := a.screenshotService.Scan(c.ImageFilePath, c.Force, c.options())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf(
+		"Scan completed for %s%d%s files\n",
+		chalk.Blue,
+		len(state.Screenshots),
+		chalk.Reset)
+
+	return nil
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	// register the formats screenshots are decoded from
+	_ "image/png"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	filterAll        = "all"
+	filterLow        = "low"
+	filterMatched    = "matched"
+	filterNotASong   = "not-a-song"
+	filterReviewed   = "reviewed"
+	filterUnmatched  = "unmatched"
+	spotifyEmbedURL  = "https://open.spotify.com/embed/track/"
+	screenshotsRoute = "/screenshots/"
+	imagesRoute      = "/images/"
+	thumbnailHeight  = 160
+	thumbnailsRoute  = "/thumbnails/"
+)
+
+var filters = []string{
+	filterAll,
+	filterMatched,
+	filterUnmatched,
+	filterLow,
+	filterReviewed,
+	filterNotASong,
+}
+
+type serveCommand struct {
+	Address   string  `short:"a" long:"address" description:"Address for the web server to listen on" default:"localhost:8081"`
+	Threshold float64 `short:"t" long:"threshold" description:"Matches with a confidence below this value are low-confidence" default:"0.5"`
+}
+
+type screenshotView struct {
+	Candidates []models.Track
+	Screenshot *models.Screenshot
+	SearchTerm string
+}
+
+type indexView struct {
+	Filter      string
+	Filters     []string
+	Query       string
+	Screenshots []*models.Screenshot
+	Threshold   float64
+}
+
+var templateFuncs = template.FuncMap{
+	"embedURL": func(t models.Track) string {
+		if t.Provider != providerSpotify {
+			return ""
+		}
+
+		return spotifyEmbedURL + t.ID
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.0f%%", f*100)
+	},
+	"title": trackTitle,
+}
+
+var pageTemplates = template.Must(template.New("layout").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<title>Song Finder</title>
+	<style>
+		body { font-family: sans-serif; margin: 1em 2em; }
+		table { border-collapse: collapse; width: 100%; }
+		td, th { border-bottom: 1px solid #ddd; padding: 0.5em; text-align: left; vertical-align: top; }
+		img.thumbnail { max-height: 160px; max-width: 120px; }
+		pre { font-size: 0.8em; max-height: 160px; overflow: auto; white-space: pre-wrap; }
+		.low { color: #c60; }
+		.none { color: #c00; }
+	</style>
+</head>
+<body>
+	<h1><a href="/">Song Finder</a></h1>
+	{{ template "content" . }}
+</body>
+</html>`))
+
+var indexTemplate = template.Must(template.Must(pageTemplates.Clone()).Parse(`{{ define "content" }}
+	<form method="get" action="/">
+		<select name="filter">
+			{{ range .Filters }}<option value="{{ . }}"{{ if eq . $.Filter }} selected{{ end }}>{{ . }}</option>{{ end }}
+		</select>
+		<input type="search" name="q" value="{{ .Query }}" placeholder="Search text, song or track" />
+		<button type="submit">Filter</button>
+	</form>
+	<p>{{ len .Screenshots }} screenshots</p>
+	<table>
+		<tr><th>Screenshot</th><th>Text</th><th>Song</th><th>Track</th><th>Confidence</th><th></th></tr>
+		{{ range .Screenshots }}
+		<tr>
+			<td><a href="/images/{{ .SHASum }}"><img class="thumbnail" src="/thumbnails/{{ .SHASum }}" alt="{{ .Path }}" /></a><div>{{ .Path }}</div></td>
+			<td><pre>{{ .Text }}</pre></td>
+			<td>{{ .SongSearchTerm }}</td>
+			<td>
+				{{ if .Track.URI }}
+				<a href="{{ .Track.URL }}">{{ title .Track }}</a>
+				{{ with embedURL .Track }}<div><a href="{{ . }}">embed</a></div>{{ end }}
+				{{ else if and .Override .Override.NotASong }}
+				<span class="none">not a song</span>
+				{{ else }}
+				<span class="none">no match</span>
+				{{ end }}
+			</td>
+			<td{{ if lt .Confidence $.Threshold }} class="low"{{ end }}>{{ percent .Confidence }}{{ if .Override }} (reviewed){{ end }}</td>
+			<td><a href="/screenshots/{{ .SHASum }}">correct</a></td>
+		</tr>
+		{{ end }}
+	</table>
+{{ end }}`))
+
+var screenshotTemplate = template.Must(template.Must(pageTemplates.Clone()).Parse(`{{ define "content" }}
+	{{ $ss := .Screenshot }}
+	<p><img src="/images/{{ $ss.SHASum }}" alt="{{ $ss.Path }}" style="max-height: 480px;" /></p>
+	<p>{{ $ss.Path }}</p>
+	<pre>{{ $ss.Text }}</pre>
+	{{ if $ss.Track.URI }}
+	<p>Matched: <a href="{{ $ss.Track.URL }}">{{ title $ss.Track }}</a> ({{ percent $ss.Confidence }})</p>
+	{{ with embedURL $ss.Track }}<iframe src="{{ . }}" width="300" height="80" frameborder="0" allow="encrypted-media"></iframe>{{ end }}
+	{{ end }}
+	<form method="get" action="/screenshots/{{ $ss.SHASum }}">
+		<input type="search" name="q" value="{{ .SearchTerm }}" size="60" />
+		<button type="submit">Search</button>
+	</form>
+	<table>
+		{{ range .Candidates }}
+		<tr>
+			<td><a href="{{ .URL }}">{{ title . }}</a></td>
+			<td>
+				<form method="post" action="/screenshots/{{ $ss.SHASum }}">
+					<input type="hidden" name="q" value="{{ $.SearchTerm }}" />
+					<input type="hidden" name="id" value="{{ .ID }}" />
+					<button type="submit" name="action" value="resolve">Choose</button>
+				</form>
+			</td>
+		</tr>
+		{{ else }}
+		<tr><td>No candidates found</td></tr>
+		{{ end }}
+	</table>
+	<form method="post" action="/screenshots/{{ $ss.SHASum }}">
+		<button type="submit" name="action" value="skip">Not a song</button>
+	</form>
+{{ end }}`))
+
+func init() {
+	parser.AddCommand(
+		"serve",
+		"Serve a local web app for reviewing matches",
+		"Serves a local web app listing every screenshot in state with a thumbnail, detected text, song and matched track, supporting filtering and manual correction",
+		&serveCommand{})
+}
+
+// Execute starts the web server
+func (c *serveCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving song-finder at http://%s/\n", c.Address)
+
+	return http.ListenAndServe(c.Address, c.handler(a))
+}
+
+func (c *serveCommand) handler(a *app) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", c.handleIndex(a))
+	mux.HandleFunc(imagesRoute, c.handleImage(a))
+	mux.HandleFunc(screenshotsRoute, c.handleScreenshot(a))
+	mux.HandleFunc(thumbnailsRoute, c.handleThumbnail(a))
+
+	return mux
+}
+
+func (c *serveCommand) handleImage(a *app) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ss, err := findScreenshot(a, strings.TrimPrefix(req.URL.Path, imagesRoute))
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+
+		if ss == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		http.ServeFile(w, req, ss.Path)
+	}
+}
+
+func (c *serveCommand) handleIndex(a *app) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+
+		state, err := a.screenshotService.State()
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+
+		v := indexView{
+			Filter:    req.FormValue("filter"),
+			Filters:   filters,
+			Query:     req.FormValue("q"),
+			Threshold: c.Threshold,
+		}
+
+		if v.Filter == "" {
+			v.Filter = filterAll
+		}
+
+		for _, ss := range sortedScreenshots(state) {
+			if c.matchesFilter(ss, v.Filter) && matchesQuery(ss, v.Query) {
+				v.Screenshots = append(v.Screenshots, ss)
+			}
+		}
+
+		render(w, indexTemplate, v)
+	}
+}
+
+func (c *serveCommand) handleScreenshot(a *app) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		shaSum := strings.TrimPrefix(req.URL.Path, screenshotsRoute)
+
+		ss, err := findScreenshot(a, shaSum)
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+
+		if ss == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		term := req.FormValue("q")
+		if term == "" {
+			term = ss.SongSearchTerm
+		}
+
+		if req.Method == http.MethodPost {
+			if !c.sameOrigin(req) {
+				http.Error(w, "cross-origin correction refused", http.StatusForbidden)
+				return
+			}
+
+			if err := c.correct(a, req, shaSum, term); err != nil {
+				renderError(w, err)
+				return
+			}
+
+			http.Redirect(w, req, "/", http.StatusSeeOther)
+			return
+		}
+
+		candidates, err := a.reviewService.Candidates(term)
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+
+		render(w, screenshotTemplate, screenshotView{
+			Candidates: candidates,
+			Screenshot: ss,
+			SearchTerm: term,
+		})
+	}
+}
+
+// correct applies the manual correction posted for the screenshot
+func (c *serveCommand) correct(a *app, req *http.Request, shaSum string, term string) error {
+	switch req.FormValue("action") {
+	case "skip":
+		return a.reviewService.Skip(shaSum)
+	case "resolve":
+		// only the ID of the candidate chosen is posted and the track
+		// is retrieved from the music provider rather than trusted
+		id := req.FormValue("id")
+		if id == "" {
+			return fmt.Errorf("no track posted for %s", shaSum)
+		}
+
+		t, err := a.reviewService.Track(id)
+		if err != nil {
+			return err
+		}
+
+		if t == nil {
+			return fmt.Errorf("track %s posted for %s not found", id, shaSum)
+		}
+
+		return a.reviewService.Resolve(shaSum, term, *t)
+	default:
+		return fmt.Errorf("unknown action %q", req.FormValue("action"))
+	}
+}
+
+// sameOrigin reports whether the request was sent to the address
+// the server listens on from a page it served, refusing corrections
+// posted by other sites open in the browser
+func (c *serveCommand) sameOrigin(req *http.Request) bool {
+	// a listen address without a specific host accepts any
+	if host, _, err := net.SplitHostPort(c.Address); err == nil && host != "" && !net.ParseIP(host).IsUnspecified() {
+		if req.Host != c.Address {
+			return false
+		}
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		origin = req.Header.Get("Referer")
+	}
+
+	// browsers send either header, so the request isn't from a page
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == req.Host
+}
+
+// handleThumbnail serves the screenshot scaled down to the height of
+// the thumbnails listed on the index as a JPEG
+func (c *serveCommand) handleThumbnail(a *app) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ss, err := findScreenshot(a, strings.TrimPrefix(req.URL.Path, thumbnailsRoute))
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+
+		if ss == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		f, err := os.Open(ss.Path)
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+		defer f.Close()
+
+		img, _, err := image.Decode(f)
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+
+		if err := jpeg.Encode(w, scaleImage(img, thumbnailHeight), nil); err != nil {
+			log.Error().Stack().Err(err).Msg("unable to write thumbnail")
+		}
+	}
+}
+
+func (c *serveCommand) matchesFilter(ss *models.Screenshot, filter string) bool {
+	switch filter {
+	case filterMatched:
+		return ss.Track.URI != ""
+	case filterUnmatched:
+		return ss.Track.URI == ""
+	case filterLow:
+		return ss.Track.URI != "" && ss.Confidence < c.Threshold
+	case filterReviewed:
+		return ss.Override != nil
+	case filterNotASong:
+		return ss.Override != nil && ss.Override.NotASong
+	default:
+		return true
+	}
+}
+
+func findScreenshot(a *app, shaSum string) (*models.Screenshot, error) {
+	state, err := a.screenshotService.State()
+	if err != nil {
+		return nil, err
+	}
+
+	return state.Screenshots[shaSum], nil
+}
+
+func matchesQuery(ss *models.Screenshot, query string) bool {
+	if query == "" {
+		return true
+	}
+
+	query = strings.ToLower(query)
+
+	for _, s := range []string{ss.Path, ss.Text, ss.SongSearchTerm, trackTitle(ss.Track)} {
+		if strings.Contains(strings.ToLower(s), query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scaleImage scales the image down to the height, keeping its aspect
+// ratio, by sampling the nearest pixel
+func scaleImage(img image.Image, height int) image.Image {
+	b := img.Bounds()
+	if b.Dy() <= height {
+		return img
+	}
+
+	width := b.Dx() * height / b.Dy()
+	if width == 0 {
+		width = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scaled.Set(x, y, img.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+
+	return scaled
+}
+
+func render(w http.ResponseWriter, t *template.Template, v interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := t.Execute(w, v); err != nil {
+		log.Error().Stack().Err(err).Msg("unable to render page")
+	}
+}
+
+func renderError(w http.ResponseWriter, err error) {
+	log.Error().Stack().Err(err).Msg("unable to handle request")
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
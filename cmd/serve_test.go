@@ -0,0 +1,308 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/repositories"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+// serveMusicProvider returns the same tracks for every search
+// and retrieves any of them by ID
+type serveMusicProvider struct {
+	interfaces.IMusicProvider
+	searches []string
+	tracks   []models.Track
+}
+
+func (mp *serveMusicProvider) GetTrack(id string) (*models.Track, error) {
+	for _, t := range []models.Track{serveCandidate, serveMatched} {
+		if t.ID == id {
+			return &t, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (mp *serveMusicProvider) Search(searchTerm string, limit int) ([]models.Track, error) {
+	mp.searches = append(mp.searches, searchTerm)
+	return mp.tracks, nil
+}
+
+var (
+	serveMatched = models.Track{
+		Artists:  []string{"SG Lewis"},
+		ID:       "chemicals",
+		Name:     "Chemicals",
+		Provider: providerSpotify,
+		URI:      "spotify:track:chemicals",
+	}
+	serveCandidate = models.Track{
+		Artists:  []string{"The Dig"},
+		ID:       "soul",
+		Name:     "Soul of the Night",
+		Provider: providerSpotify,
+		URI:      "spotify:track:soul",
+	}
+)
+
+// newServeApp returns an app for a state with a matched and an
+// unmatched screenshot, each a 600 by 1200 image
+func newServeApp(t *testing.T) (*app, *serveMusicProvider, interfaces.IStateRepository) {
+	dir := t.TempDir()
+
+	state := models.State{Screenshots: map[string]*models.Screenshot{}}
+	for _, ss := range []*models.Screenshot{
+		{Confidence: 0.9, SHASum: "matched", SongSearchTerm: "sg lewis chemicals", Text: "SG Lewis\nChemicals", Track: serveMatched},
+		{SHASum: "unmatched", SongSearchTerm: "the dig soul of the night", Text: "The Dig - Soul of the Night"},
+	} {
+		ss.Path = filepath.Join(dir, ss.SHASum+".png")
+		writeServeImage(t, ss.Path, 600, 1200)
+		state.Screenshots[ss.SHASum] = ss
+	}
+
+	str := repositories.NewStateRepository(filepath.Join(dir, stateFileName))
+	if err := str.Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	smp := &serveMusicProvider{tracks: []models.Track{serveCandidate}}
+	var mp interfaces.IMusicProvider = smp
+
+	return &app{
+		reviewService:     services.NewReviewService(&mp, &str),
+		screenshotService: services.NewScreenshotService(nil, &mp, &str, nil, nil),
+	}, smp, str
+}
+
+func writeServeImage(t *testing.T, path string, width int, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 18, G: 18, B: 18, A: 255})
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadServeState(t *testing.T, str interfaces.IStateRepository) models.State {
+	var state models.State
+	if err := str.Load(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	return state
+}
+
+func TestServeFiltersIndex(t *testing.T) {
+	a, _, _ := newServeApp(t)
+	h := (&serveCommand{Threshold: 0.5}).handler(a)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"matched.png", "unmatched.png"}},
+		{"?filter=matched", []string{"matched.png"}},
+		{"?filter=unmatched", []string{"unmatched.png"}},
+		{"?filter=reviewed", nil},
+		{"?q=soul", []string{"unmatched.png"}},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+test.query, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected the index to be served for %q: %d %s", test.query, rec.Code, rec.Body)
+		}
+
+		body := rec.Body.String()
+		for _, name := range []string{"matched.png", "unmatched.png"} {
+			listed := strings.Contains(body, "/"+name+"\"")
+			expected := false
+			for _, e := range test.expected {
+				expected = expected || e == name
+			}
+
+			if listed != expected {
+				t.Errorf("expected %s to be listed %t for %q", name, expected, test.query)
+			}
+		}
+	}
+}
+
+func TestServeResolvesChosenTrack(t *testing.T) {
+	a, mp, str := newServeApp(t)
+	h := (&serveCommand{}).handler(a)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/screenshots/unmatched", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Soul of the Night") {
+		t.Fatalf("expected the candidates to be listed: %d %s", rec.Code, rec.Body)
+	}
+
+	// the candidates of the search change before the track is chosen
+	mp.searches, mp.tracks = nil, nil
+
+	form := url.Values{
+		"action": {"resolve"},
+		"id":     {serveCandidate.ID},
+		"q":      {"the dig soul of the night"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/screenshots/unmatched", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect to the index: %d %s", rec.Code, rec.Body)
+	}
+
+	if len(mp.searches) != 0 {
+		t.Errorf("expected the chosen track to be resolved without searching: %v", mp.searches)
+	}
+
+	ss := loadServeState(t, str).Screenshots["unmatched"]
+	if ss.Override == nil || ss.Track.URI != serveCandidate.URI || ss.Status != models.StatusManuallyResolved {
+		t.Errorf("expected the chosen track to be recorded: %+v", ss)
+	}
+}
+
+func TestServeRefusesCrossOriginCorrections(t *testing.T) {
+	tests := []struct {
+		host     string
+		origin   string
+		referer  string
+		expected int
+	}{
+		{"localhost:8081", "", "", http.StatusSeeOther},
+		{"localhost:8081", "http://localhost:8081", "", http.StatusSeeOther},
+		{"localhost:8081", "", "http://localhost:8081/screenshots/unmatched", http.StatusSeeOther},
+		{"localhost:8081", "http://evil.example", "", http.StatusForbidden},
+		{"localhost:8081", "", "http://evil.example/", http.StatusForbidden},
+		{"evil.example:8081", "http://evil.example:8081", "", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		a, _, str := newServeApp(t)
+		h := (&serveCommand{Address: "localhost:8081"}).handler(a)
+
+		form := url.Values{"action": {"resolve"}, "id": {serveMatched.ID}}
+		req := httptest.NewRequest(http.MethodPost, "/screenshots/unmatched", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Host = test.host
+
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+
+		if test.referer != "" {
+			req.Header.Set("Referer", test.referer)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != test.expected {
+			t.Errorf("expected %d for host %q, origin %q and referer %q: %d", test.expected, test.host, test.origin, test.referer, rec.Code)
+		}
+
+		if recorded := loadServeState(t, str).Screenshots["unmatched"].Override != nil; recorded != (test.expected == http.StatusSeeOther) {
+			t.Errorf("expected the correction for origin %q to be recorded %t", test.origin, !recorded)
+		}
+	}
+}
+
+func TestServeRefusesUnknownTrack(t *testing.T) {
+	a, _, str := newServeApp(t)
+	h := (&serveCommand{}).handler(a)
+
+	for _, id := range []string{"", "missing"} {
+		form := url.Values{"action": {"resolve"}, "id": {id}}
+		req := httptest.NewRequest(http.MethodPost, "/screenshots/unmatched", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code == http.StatusSeeOther {
+			t.Errorf("expected track %q not to be resolved", id)
+		}
+	}
+
+	if ss := loadServeState(t, str).Screenshots["unmatched"]; ss.Override != nil {
+		t.Errorf("expected no track to be recorded: %+v", ss)
+	}
+}
+
+func TestServeSkipsScreenshot(t *testing.T) {
+	a, _, str := newServeApp(t)
+	h := (&serveCommand{}).handler(a)
+
+	req := httptest.NewRequest(http.MethodPost, "/screenshots/matched", strings.NewReader("action=skip"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect to the index: %d %s", rec.Code, rec.Body)
+	}
+
+	ss := loadServeState(t, str).Screenshots["matched"]
+	if ss.Override == nil || !ss.Override.NotASong || ss.Track.URI != "" {
+		t.Errorf("expected the screenshot to be recorded as not a song: %+v", ss)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/screenshots/missing", strings.NewReader("")))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected a screenshot not in state to be not found: %d", rec.Code)
+	}
+}
+
+func TestServeScalesThumbnails(t *testing.T) {
+	a, _, _ := newServeApp(t)
+	h := (&serveCommand{}).handler(a)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/thumbnails/matched", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("expected a JPEG thumbnail: %d %s", rec.Code, rec.Header())
+	}
+
+	cfg, _, err := image.DecodeConfig(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Width != 80 || cfg.Height != thumbnailHeight {
+		t.Errorf("expected the screenshot to be scaled to 80x%d: %dx%d", thumbnailHeight, cfg.Width, cfg.Height)
+	}
+}
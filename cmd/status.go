@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/ttacon/chalk"
+)
+
+type statusCommand struct{}
+
+func init() {
+	parser.AddCommand(
+		"status",
+		"Summarize the state file",
+		"Prints counts of the screenshots scanned, searched and matched in the state file",
+		&statusCommand{})
+}
+
+// Execute prints a summary of the state
+func (c *statusCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	state, err := a.screenshotService.State()
+	if err != nil {
+		return err
+	}
+
+	var detected, notMusic, unparsed, searched, matched, reviewed int
+
+	for _, ss := range state.Screenshots {
+		switch ss.Status {
+		case models.StatusNotMusic:
+			notMusic++
+		case models.StatusUnparsed:
+			unparsed++
+		}
+
+		if ss.Text != "" {
+			detected++
+		}
+
+		if !ss.LastSearched.IsZero() {
+			searched++
+		}
+
+		if ss.Track.URI != "" {
+			matched++
+		}
+
+		if ss.Override != nil {
+			reviewed++
+		}
+	}
+
+	printStat("Screenshots", len(state.Screenshots))
+	printStat("Not music", notMusic)
+	printStat("Text detected", detected)
+	printStat("No song found", unparsed)
+	printStat("Searched", searched)
+	printStat("Matched", matched)
+	printStat("Unmatched", searched-matched)
+	printStat("Reviewed", reviewed)
+
+	if !state.Completed.IsZero() {
+		fmt.Println(chalk.Blue, "Last completed:", chalk.Reset, state.Completed.Format("2006-01-02 15:04:05"))
+	}
+
+	fmt.Println(chalk.Blue, "Software version:", chalk.Reset, state.SoftwareVersion)
+
+	return nil
+}
+
+func printStat(label string, count int) {
+	fmt.Printf(" %s%s:%s %d\n", chalk.Blue, label, chalk.Reset, count)
+}
@@ -0,0 +1,29 @@
+package main
+
+type syncCommand struct {
+	playlistOptions
+	PlaylistName string `short:"n" long:"playlist" description:"Name of the playlist to sync, a template when partitioning" required:"true"`
+}
+
+func init() {
+	parser.AddCommand(
+		"sync",
+		"Sync matched tracks to a playlist",
+		"Ensures the playlist exists and contains each track matched from the screenshots",
+		&syncCommand{})
+}
+
+// Execute ensures the playlist contains the matched tracks
+func (c *syncCommand) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	state, err := a.screenshotService.State()
+	if err != nil {
+		return err
+	}
+
+	return c.sync(a, c.PlaylistName, state)
+}
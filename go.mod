@@ -15,8 +15,8 @@ require (
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777 // indirect
 	golang.org/x/oauth2 v0.0.0-20210126194326-f9ce19ea3013
 	golang.org/x/sys v0.0.0-20210216163648-f7da38b97c65 // indirect
-	golang.org/x/text v0.3.5 // indirect
-	google.golang.org/api v0.37.0 // indirect
-	google.golang.org/genproto v0.0.0-20210126160654-44e461bb6506 // indirect
-	google.golang.org/grpc v1.35.0 // indirect
+	golang.org/x/text v0.3.5
+	google.golang.org/api v0.37.0
+	google.golang.org/genproto v0.0.0-20210126160654-44e461bb6506
+	google.golang.org/grpc v1.35.0
 )
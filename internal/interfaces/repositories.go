@@ -2,21 +2,52 @@ package interfaces
 
 import (
 	"github.com/brozeph/song-finder/internal/models"
-	"github.com/zmb3/spotify"
 )
 
+// IArtistRepository provides the artists known to the user, which
+// text detected in screenshots is corrected against
+type IArtistRepository interface {
+	Artists() ([]string, error)
+}
+
+// ICorpusRepository provides methods for maintaining the corpus of
+// labeled annotations the screenshot parser is tested against
+type ICorpusRepository interface {
+	Add(fixture models.Fixture) error
+	Fixtures() ([]models.Fixture, error)
+}
+
+// IMusicProvider provides methods to abstract interaction with
+// a music service for finding tracks and maintaining playlists
+type IMusicProvider interface {
+	AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error)
+	CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error)
+	FindPlaylist(name string) (*models.Playlist, error)
+	GetTrack(id string) (*models.Track, error)
+	Search(searchTerm string, limit int) ([]models.Track, error)
+	SearchISRC(isrc string, limit int) ([]models.Track, error)
+}
+
+// IMetadataRepository provides methods to verify songs against
+// a music metadata service
+type IMetadataRepository interface {
+	FindRecording(searchTerm string) (*models.Recording, error)
+}
+
 // IScreenshotRepository provides methods for retrieving screenshots
 // from the filesystem
 type IScreenshotRepository interface {
+	DetectLabels(path string) ([]models.Label, error)
 	DetectText(path string) (string, error)
-	FindInPath(path string) ([]*models.Screenshot, error)
+	FindInPath(path string, opts models.DiscoveryOptions) ([]*models.Screenshot, error)
 }
 
 // ISpotifyRepository provides methods to abstract interaction with the
 // Spotify API
 type ISpotifyRepository interface {
-	CreatePlaylist(user string, name string, tracks []spotify.SimpleTrack) error
-	Search(searchTerm string) (spotify.SimpleTrack, error)
+	IMusicProvider
+	GetGenres(track models.Track) ([]string, error)
+	GetPlaylistTracks(playlist models.Playlist) ([]models.Track, error)
 }
 
 // IStateRepository provides methods to persist and retrieve state
@@ -1,17 +1,53 @@
 package interfaces
 
 import (
+	"io"
+
 	"github.com/brozeph/song-finder/internal/models"
-	"github.com/zmb3/spotify"
 )
 
+// ICorpusService provides methods for capturing fixtures of the
+// corpus from scanned screenshots and measuring the accuracy of the
+// screenshot parser and matcher across the corpus
+type ICorpusService interface {
+	Add(path string, fixture models.Fixture, record bool) (models.Fixture, error)
+	Evaluate() (models.EvalReport, error)
+	Report() (models.CorpusReport, error)
+}
+
+// IPlaylistService provides methods for maintaining the playlist
+// of matched tracks with the music provider
 type IPlaylistService interface {
-	EnsurePlaylist(name string, tracks []spotify.SimpleTrack) error
+	Cover(screenshots []*models.Screenshot) ([]byte, error)
+	Description(text string, screenshots []*models.Screenshot) (string, error)
+	EnsurePlaylist(name string, tracks []models.Track, opts models.PlaylistOptions) (models.Playlist, error)
+	Partition(rule string, name string, screenshots []*models.Screenshot) ([]models.PlaylistPartition, error)
 }
 
 // IScreenshotService provides the workflow for processing screenshots
-// and creating Spotify playlists
+// and matching them to tracks with the music provider
 type IScreenshotService interface {
-	Begin(path string) (models.State, error)
-	SearchTerm(annotation string) string
+	Begin(path string, opts models.DiscoveryOptions) (models.State, error)
+	Match(force bool) (models.State, error)
+	Scan(path string, force bool, opts models.DiscoveryOptions) (models.State, error)
+	SearchTerm(annotation string) models.SearchTerm
+	SearchTerms(annotation string) []models.SearchTerm
+	State() (models.State, error)
+}
+
+// IReviewService provides methods for manually resolving screenshots
+// whose match is missing or has a low confidence
+type IReviewService interface {
+	Candidates(searchTerm string) ([]models.Track, error)
+	Pending(threshold float64) ([]*models.Screenshot, error)
+	Resolve(shaSum string, searchTerm string, track models.Track) error
+	Skip(shaSum string) error
+	Track(id string) (*models.Track, error)
+}
+
+// IExportService provides methods for writing the screenshots in
+// state in machine-readable formats
+type IExportService interface {
+	Export(w io.Writer, format string, order string, state models.State) error
+	Formats() []string
 }
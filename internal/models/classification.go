@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Classification is the verdict of classifying a screenshot before
+// detecting text, so only probable music screenshots are sent for OCR
+type Classification struct {
+	Classified time.Time
+	Classifier string
+	Music      bool
+	Reason     string
+	Score      float64
+}
@@ -0,0 +1,104 @@
+package models
+
+import "encoding/json"
+
+// Fixture is a labeled annotation in the corpus the screenshot parser
+// is tested against, where Term is the exact search term expected from
+// the parser when it is known. A fixture without an artist and title
+// is expected to contain no song
+type Fixture struct {
+	Annotation string
+	Artist     string
+	Layout     json.RawMessage
+	Name       string
+	Responses  RecordedResponses
+	Source     string
+	Term       string
+	Title      string
+}
+
+// HasSong returns true when the fixture is labeled with a song
+func (f Fixture) HasSong() bool {
+	return f.Artist != "" || f.Title != ""
+}
+
+// RecordedResponses are the tracks a music provider returned for each
+// search term and ISRC searched when the fixture was recorded
+type RecordedResponses struct {
+	ISRC   map[string][]Track `json:",omitempty"`
+	Search map[string][]Track `json:",omitempty"`
+}
+
+// Empty returns true when no responses are recorded
+func (rr RecordedResponses) Empty() bool {
+	return len(rr.ISRC) == 0 && len(rr.Search) == 0
+}
+
+// CorpusReport is the accuracy of the parser across the corpus
+type CorpusReport struct {
+	Sources []SourceAccuracy
+	Total   SourceAccuracy
+}
+
+// SourceAccuracy is the number of fixtures of a source the parser
+// found the expected artist and title in, along with the names of
+// the fixtures it failed to
+type SourceAccuracy struct {
+	Correct  int
+	Failures []string
+	Fixtures int
+	Source   string
+}
+
+// Accuracy returns the portion of fixtures parsed correctly
+func (sa SourceAccuracy) Accuracy() float64 {
+	if sa.Fixtures == 0 {
+		return 0
+	}
+
+	return float64(sa.Correct) / float64(sa.Fixtures)
+}
+
+// EvalReport is the precision and recall of parsing and searching
+// across the corpus
+type EvalReport struct {
+	Sources []SourceEvaluation
+	Total   SourceEvaluation
+}
+
+// SourceEvaluation is the precision and recall of each stage of
+// matching the fixtures of a source
+type SourceEvaluation struct {
+	Parse  StageAccuracy
+	Search StageAccuracy
+	Source string
+}
+
+// StageAccuracy counts the fixtures labeled with a song (Expected),
+// the fixtures a stage found a song in (Predicted) and those it found
+// the expected song in (Correct), along with the names of the fixtures
+// it found no song or the wrong song in
+type StageAccuracy struct {
+	Correct   int
+	Expected  int
+	Failures  []string
+	Predicted int
+}
+
+// Precision returns the portion of the songs found that were expected
+func (sa StageAccuracy) Precision() float64 {
+	if sa.Predicted == 0 {
+		return 0
+	}
+
+	return float64(sa.Correct) / float64(sa.Predicted)
+}
+
+// Recall returns the portion of the expected songs that were found
+func (sa StageAccuracy) Recall() float64 {
+	if sa.Expected == 0 {
+		return 0
+	}
+
+	return float64(sa.Correct) / float64(sa.Expected)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DiscoveryOptions control which images are discovered as screenshots
+// when scanning a path and the classifier used to decide which of them
+// are sent for text detection
+type DiscoveryOptions struct {
+	Classifier     string
+	Exclude        []string
+	FollowSymlinks bool
+	Include        []string
+	MaxDepth       int
+	Since          time.Time
+	Until          time.Time
+}
@@ -0,0 +1,8 @@
+package models
+
+// Label is a description of the content of an image detected by
+// the Google Cloud vision API
+type Label struct {
+	Description string
+	Score       float64
+}
@@ -0,0 +1,7 @@
+package models
+
+// Location is the position recorded in the GPS metadata of an image
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
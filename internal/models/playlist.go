@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Playlist is a playlist belonging to the user of a music provider
+type Playlist struct {
+	Collaborative bool   `json:",omitempty"`
+	Description   string `json:",omitempty"`
+	ID            string
+	Name          string
+	Owner         string `json:",omitempty"`
+	Provider      string
+	Public        bool   `json:",omitempty"`
+	SnapshotID    string `json:",omitempty"`
+	TrackCount    int    `json:",omitempty"`
+	URI           string
+	URL           string
+}
+
+// PlaylistOptions are the details applied to a playlist when it
+// is created with the music provider
+type PlaylistOptions struct {
+	Collaborative bool
+	Cover         []byte
+	Description   string
+	Public        bool
+}
+
+// PlaylistPartition is a playlist named for a group of screenshots
+// split from the matched screenshots by a partition rule
+type PlaylistPartition struct {
+	Name        string
+	Screenshots []*Screenshot
+}
+
+// PlaylistBatch is a set of tracks added to a playlist in a single
+// request and the snapshot of the playlist returned by the provider
+type PlaylistBatch struct {
+	Added      time.Time
+	SnapshotID string `json:",omitempty"`
+	TrackIDs   []string
+}
+
+// PlaylistSync records the batches of tracks added to a playlist so
+// an interrupted sync resumes without adding duplicate tracks
+type PlaylistSync struct {
+	Batches  []PlaylistBatch
+	Playlist Playlist
+}
+
+// Contains returns true when the track has already been added to
+// the playlist
+func (ps *PlaylistSync) Contains(id string) bool {
+	for _, b := range ps.Batches {
+		for _, tid := range b.TrackIDs {
+			if tid == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
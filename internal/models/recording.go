@@ -0,0 +1,11 @@
+package models
+
+// Recording is the canonical metadata for a song found with
+// a music metadata service such as MusicBrainz
+type Recording struct {
+	Artists []string
+	ID      string
+	ISRCs   []string
+	Score   int
+	Title   string
+}
@@ -2,16 +2,103 @@ package models
 
 import (
 	"time"
-
-	"github.com/zmb3/spotify"
 )
 
+// Override records a manual decision made while reviewing a
+// screenshot, which subsequent runs respect instead of searching
+type Override struct {
+	NotASong bool
+	Reviewed time.Time
+}
+
 // Screenshot contains the details / state for every
-// screenshot image being processed
+// screenshot image being processed. Track is the first matched song
+// when the screenshot lists multiple Songs
 type Screenshot struct {
+	Captured       time.Time
+	Classification *Classification `json:",omitempty"`
+	Confidence     float64
+	Device         string `json:",omitempty"`
+	LastDetected   time.Time
 	LastSearched   time.Time
+	Location       *Location `json:",omitempty"`
+	Override       *Override `json:",omitempty"`
 	Path           string
+	Recording      *Recording `json:",omitempty"`
 	SHASum         string
+	Songs          []Song `json:",omitempty"`
 	SongSearchTerm string
-	SpotifyTrack   spotify.SimpleTrack
+	SearchReason   string       `json:",omitempty"`
+	SpotifyTrack   *legacyTrack `json:",omitempty"`
+	Status         Status       `json:",omitempty"`
+	Text           string
+	Track          Track
+}
+
+// Tracks returns the tracks matched to the screenshot, which are
+// those of each of its songs unless the match was manually resolved
+func (s *Screenshot) Tracks() []Track {
+	if len(s.Songs) == 0 || s.Override != nil {
+		if s.Track.URI == "" {
+			return nil
+		}
+
+		return []Track{s.Track}
+	}
+
+	var tracks []Track
+	for _, song := range s.Songs {
+		if song.Track.URI != "" {
+			tracks = append(tracks, song.Track)
+		}
+	}
+
+	return tracks
+}
+
+// Migrate moves the Spotify track from a state file written by
+// a previous version of the software to Track and derives the status
+// of screenshots recorded before it was
+func (s *Screenshot) Migrate() {
+	defer s.migrateStatus()
+
+	if s.SpotifyTrack == nil {
+		return
+	}
+
+	if s.Track.URI == "" && s.SpotifyTrack.URI != "" {
+		s.Track = Track{
+			DurationMS: s.SpotifyTrack.DurationMS,
+			ID:         s.SpotifyTrack.ID,
+			Name:       s.SpotifyTrack.Name,
+			Provider:   "spotify",
+			URI:        s.SpotifyTrack.URI,
+			URL:        "https://open.spotify.com/track/" + s.SpotifyTrack.ID,
+		}
+
+		for _, a := range s.SpotifyTrack.Artists {
+			s.Track.Artists = append(s.Track.Artists, a.Name)
+		}
+	}
+
+	s.SpotifyTrack = nil
+}
+
+func (s *Screenshot) migrateStatus() {
+	if s.Status != "" {
+		return
+	}
+
+	switch {
+	case s.Override != nil && s.Override.NotASong:
+		s.Status = StatusNotMusic
+	case s.Override != nil:
+		s.Status = StatusManuallyResolved
+	case s.Track.URI != "":
+		s.Status = StatusMatched
+	case s.Classification != nil && !s.Classification.Music:
+		s.Status = StatusNotMusic
+	case !s.LastSearched.IsZero():
+		s.Status = StatusUnmatched
+	}
 }
@@ -0,0 +1,28 @@
+package models
+
+// SearchTerm is the song parsed from the text detected in a
+// screenshot, along with how confident the parser is that the text
+// is a song and the layout or pattern the song was found by
+type SearchTerm struct {
+	Confidence float64
+	Hints      TitleHints
+	Reason     string
+	Term       string
+}
+
+// Parsed returns true when the song was found by a known layout or
+// the divider between artist and title
+func (st SearchTerm) Parsed() bool {
+	return st.Confidence > 0
+}
+
+// TitleHints are the details of the version of a song separated from
+// its title, such as "(Live at ...)" or "- Remastered 2011", which
+// hint at the track that best matches the song
+type TitleHints struct {
+	Edited     bool
+	Featured   []string
+	Live       bool
+	Remastered bool
+	Truncated  bool
+}
@@ -0,0 +1,13 @@
+package models
+
+// Song is one of the songs listed in a screenshot of a history or
+// library, such as Shazam's library or a radio station's recently
+// played page, and the track it was matched to
+type Song struct {
+	Confidence float64
+	Reason     string     `json:",omitempty"`
+	Recording  *Recording `json:",omitempty"`
+	SearchTerm string
+	Status     Status
+	Track      Track
+}
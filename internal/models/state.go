@@ -6,6 +6,7 @@ import "time"
 // the  song finder
 type State struct {
 	Completed       time.Time
+	Playlists       map[string]*PlaylistSync `json:",omitempty"`
 	Screenshots     map[string]*Screenshot
 	SoftwareVersion string
 }
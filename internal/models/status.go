@@ -0,0 +1,15 @@
+package models
+
+// Status is the stage a screenshot has reached in being matched
+// to a track
+type Status string
+
+// Statuses recorded in state for each screenshot
+const (
+	StatusManuallyResolved Status = "ManuallyResolved"
+	StatusMatched          Status = "Matched"
+	StatusNotMusic         Status = "NotMusic"
+	StatusParsed           Status = "Parsed"
+	StatusUnmatched        Status = "Unmatched"
+	StatusUnparsed         Status = "Unparsed"
+)
@@ -0,0 +1,26 @@
+package models
+
+// Track is a song found with a music provider
+type Track struct {
+	Album      string
+	Artists    []string
+	DurationMS int
+	ID         string
+	ISRC       string
+	Name       string
+	Provider   string
+	URI        string
+	URL        string
+}
+
+// legacyTrack is the Spotify track stored in state files written
+// before tracks were independent of the music provider
+type legacyTrack struct {
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	DurationMS int    `json:"duration_ms"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+}
@@ -0,0 +1,463 @@
+package repositories
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	appleMusicBaseURL           = "https://api.music.apple.com"
+	appleMusicDefaultStorefront = "us"
+	appleMusicProvider          = "applemusic"
+	appleMusicTokenLifetime     = 12 * time.Hour
+)
+
+// AppleMusicOptions contains the credentials and settings for
+// the Apple Music API
+type AppleMusicOptions struct {
+	// BaseURL of the Apple Music API, which defaults to
+	// https://api.music.apple.com
+	BaseURL string
+	// KeyID of the MusicKit private key
+	KeyID string
+	// PrivateKeyPath to the .p8 MusicKit private key used to sign
+	// the developer token
+	PrivateKeyPath string
+	// Storefront for catalog searches, which defaults to us
+	Storefront string
+	// TeamID of the Apple developer account
+	TeamID string
+	// UserToken is the Music User Token authorizing access to
+	// the user's library
+	UserToken string
+}
+
+type appleMusicRepository struct {
+	client         *http.Client
+	developerToken string
+	lock           sync.Mutex
+	options        AppleMusicOptions
+	tokenExpires   time.Time
+}
+
+type appleMusicResource struct {
+	Attributes struct {
+		AlbumName        string `json:"albumName"`
+		ArtistName       string `json:"artistName"`
+		DurationInMillis int    `json:"durationInMillis"`
+		ISRC             string `json:"isrc"`
+		Name             string `json:"name"`
+		URL              string `json:"url"`
+	} `json:"attributes"`
+	Href string `json:"href"`
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type appleMusicResponse struct {
+	Data   []appleMusicResource `json:"data"`
+	Errors []struct {
+		Detail string `json:"detail"`
+		Status string `json:"status"`
+		Title  string `json:"title"`
+	} `json:"errors"`
+	Next    string `json:"next"`
+	Results struct {
+		Songs struct {
+			Data []appleMusicResource `json:"data"`
+		} `json:"songs"`
+	} `json:"results"`
+}
+
+// NewAppleMusicRepository returns a new instance
+func NewAppleMusicRepository(options AppleMusicOptions) interfaces.IMusicProvider {
+	if options.BaseURL == "" {
+		options.BaseURL = appleMusicBaseURL
+	}
+
+	if options.Storefront == "" {
+		options.Storefront = appleMusicDefaultStorefront
+	}
+
+	return &appleMusicRepository{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		options: options,
+	}
+}
+
+// AddTracks adds the tracks to the library playlist in a single
+// request, Apple Music does not return a snapshot of the playlist
+func (r *appleMusicRepository) AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error) {
+	if len(tracks) == 0 {
+		return nil, nil
+	}
+
+	body := struct {
+		Data []map[string]string `json:"data"`
+	}{}
+
+	batch := models.PlaylistBatch{}
+	for _, t := range tracks {
+		body.Data = append(body.Data, map[string]string{
+			"id":   t.ID,
+			"type": "songs",
+		})
+		batch.TrackIDs = append(batch.TrackIDs, t.ID)
+	}
+
+	if err := r.request(
+		http.MethodPost,
+		fmt.Sprintf("/v1/me/library/playlists/%s/tracks", url.PathEscape(playlist.ID)),
+		body,
+		nil); err != nil {
+		return nil, err
+	}
+
+	batch.Added = time.Now()
+
+	return []models.PlaylistBatch{batch}, nil
+}
+
+// CreatePlaylist creates a library playlist with the name and
+// description, Apple Music library playlists are always private and
+// do not support collaboration or custom artwork through the API
+func (r *appleMusicRepository) CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	if opts.Collaborative || opts.Public || len(opts.Cover) > 0 {
+		log.Warn().
+			Str("playlist", name).
+			Msg("Apple Music library playlists are private and do not support collaboration or cover images")
+	}
+
+	body := map[string]interface{}{
+		"attributes": map[string]string{
+			"description": playlistDescription(opts),
+			"name":        name,
+		},
+	}
+
+	res := appleMusicResponse{}
+	if err := r.request(http.MethodPost, "/v1/me/library/playlists", body, &res); err != nil {
+		return models.Playlist{}, err
+	}
+
+	if len(res.Data) == 0 {
+		return models.Playlist{}, errors.New("apple music: no playlist returned when creating playlist")
+	}
+
+	return toAppleMusicPlaylist(res.Data[0]), nil
+}
+
+func (r *appleMusicRepository) FindPlaylist(name string) (*models.Playlist, error) {
+	next := "/v1/me/library/playlists?limit=100"
+
+	for next != "" {
+		res := appleMusicResponse{}
+		if err := r.request(http.MethodGet, next, nil, &res); err != nil {
+			return nil, err
+		}
+
+		for _, pl := range res.Data {
+			if pl.Attributes.Name == name {
+				found := toAppleMusicPlaylist(pl)
+				return &found, nil
+			}
+		}
+
+		next = res.Next
+	}
+
+	return nil, nil
+}
+
+// GetTrack retrieves the song by its Apple Music catalog ID and
+// returns nil when the song does not exist
+func (r *appleMusicRepository) GetTrack(id string) (*models.Track, error) {
+	q := url.Values{}
+	q.Set("ids", id)
+
+	res := appleMusicResponse{}
+	if err := r.request(
+		http.MethodGet,
+		fmt.Sprintf(
+			"/v1/catalog/%s/songs?%s",
+			url.PathEscape(r.options.Storefront),
+			q.Encode()),
+		nil,
+		&res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Data) == 0 {
+		return nil, nil
+	}
+
+	track := toAppleMusicTrack(res.Data[0])
+
+	return &track, nil
+}
+
+func (r *appleMusicRepository) Search(searchTerm string, limit int) ([]models.Track, error) {
+	if len(searchTerm) == 0 {
+		return nil, nil
+	}
+
+	log.Debug().Str("song", searchTerm).Msg("searching Apple Music for song")
+
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("term", searchTerm)
+	q.Set("types", "songs")
+
+	res := appleMusicResponse{}
+	if err := r.request(
+		http.MethodGet,
+		fmt.Sprintf(
+			"/v1/catalog/%s/search?%s",
+			url.PathEscape(r.options.Storefront),
+			q.Encode()),
+		nil,
+		&res); err != nil {
+		return nil, err
+	}
+
+	songs := res.Results.Songs.Data
+	if len(songs) == 0 {
+		log.Debug().Str("song", searchTerm).Msg("no matches found for song")
+		return nil, nil
+	}
+
+	tracks := make([]models.Track, 0, len(songs))
+	for _, s := range songs {
+		tracks = append(tracks, toAppleMusicTrack(s))
+	}
+
+	return tracks, nil
+}
+
+func (r *appleMusicRepository) SearchISRC(isrc string, limit int) ([]models.Track, error) {
+	if len(isrc) == 0 {
+		return nil, nil
+	}
+
+	q := url.Values{}
+	q.Set("filter[isrc]", isrc)
+
+	res := appleMusicResponse{}
+	if err := r.request(
+		http.MethodGet,
+		fmt.Sprintf(
+			"/v1/catalog/%s/songs?%s",
+			url.PathEscape(r.options.Storefront),
+			q.Encode()),
+		nil,
+		&res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Data) > limit {
+		res.Data = res.Data[:limit]
+	}
+
+	tracks := make([]models.Track, 0, len(res.Data))
+	for _, s := range res.Data {
+		tracks = append(tracks, toAppleMusicTrack(s))
+	}
+
+	return tracks, nil
+}
+
+// ensureDeveloperToken returns the developer token, signing a new
+// one when it does not exist or is about to expire
+func (r *appleMusicRepository) ensureDeveloperToken() (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.developerToken != "" && time.Now().Add(time.Minute).Before(r.tokenExpires) {
+		return r.developerToken, nil
+	}
+
+	pk, err := ioutil.ReadFile(r.options.PrivateKeyPath)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := parseP8Key(pk)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token, err := signES256JWT(
+		key,
+		map[string]string{
+			"alg": "ES256",
+			"kid": r.options.KeyID,
+		},
+		map[string]interface{}{
+			"exp": now.Add(appleMusicTokenLifetime).Unix(),
+			"iat": now.Unix(),
+			"iss": r.options.TeamID,
+		})
+	if err != nil {
+		return "", err
+	}
+
+	r.developerToken = token
+	r.tokenExpires = now.Add(appleMusicTokenLifetime)
+
+	return r.developerToken, nil
+}
+
+// request sends the body, when supplied, as JSON to the path of the Apple
+// Music API and decodes the JSON response into v, when supplied
+func (r *appleMusicRepository) request(method string, path string, body interface{}, v interface{}) error {
+	token, err := r.ensureDeveloperToken()
+	if err != nil {
+		return err
+	}
+
+	var rdr io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		rdr = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, r.options.BaseURL+path, rdr)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	if r.options.UserToken != "" {
+		req.Header.Set("Music-User-Token", r.options.UserToken)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		amr := appleMusicResponse{}
+		if err := json.NewDecoder(res.Body).Decode(&amr); err == nil && len(amr.Errors) > 0 {
+			return fmt.Errorf(
+				"apple music: %s %s: %s (%s)",
+				method,
+				path,
+				amr.Errors[0].Title,
+				amr.Errors[0].Detail)
+		}
+
+		return fmt.Errorf("apple music: %s %s: %s", method, path, res.Status)
+	}
+
+	if v == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// parseP8Key parses the PEM encoded PKCS #8 private key downloaded
+// from the Apple developer portal
+func parseP8Key(b []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("apple music: private key is not PEM encoded")
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := k.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apple music: private key is not an ECDSA key")
+	}
+
+	return key, nil
+}
+
+// signES256JWT returns a JWT for the header and claims signed
+// with ECDSA using P-256 and SHA-256
+func signES256JWT(key *ecdsa.PrivateKey, header interface{}, claims interface{}) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(h) +
+		"." +
+		base64.RawURLEncoding.EncodeToString(c)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sr, ss, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// the JWS signature is R and S as fixed size big-endian integers
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	sr.FillBytes(sig[:size])
+	ss.FillBytes(sig[size:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func toAppleMusicPlaylist(pl appleMusicResource) models.Playlist {
+	return models.Playlist{
+		ID:       pl.ID,
+		Name:     pl.Attributes.Name,
+		Provider: appleMusicProvider,
+		URI:      fmt.Sprintf("%s:%s:%s", appleMusicProvider, pl.Type, pl.ID),
+		URL:      pl.Attributes.URL,
+	}
+}
+
+func toAppleMusicTrack(s appleMusicResource) models.Track {
+	return models.Track{
+		Album:      s.Attributes.AlbumName,
+		Artists:    []string{s.Attributes.ArtistName},
+		DurationMS: s.Attributes.DurationInMillis,
+		ID:         s.ID,
+		ISRC:       s.Attributes.ISRC,
+		Name:       s.Attributes.Name,
+		Provider:   appleMusicProvider,
+		URI:        fmt.Sprintf("%s:%s:%s", appleMusicProvider, s.Type, s.ID),
+		URL:        s.Attributes.URL,
+	}
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+)
+
+type artistRepository struct {
+	path string
+}
+
+// NewArtistRepository returns an instance of IArtistRepository for
+// reading the artists listed one per line in the file at the path,
+// ignoring blank lines and lines starting with #
+func NewArtistRepository(path string) interfaces.IArtistRepository {
+	return &artistRepository{
+		path: path,
+	}
+}
+
+// Artists returns the artists listed in the file
+func (r *artistRepository) Artists() ([]string, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var artists []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		artists = append(artists, line)
+	}
+
+	return artists, scanner.Err()
+}
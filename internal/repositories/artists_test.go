@@ -0,0 +1,26 @@
+package repositories_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/repositories"
+)
+
+func TestArtists(t *testing.T) {
+	artists, err := repositories.NewArtistRepository("testdata/artists/artists.txt").Artists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Reverend Freakchild", "Smallpools", "SG Lewis"}
+	if !reflect.DeepEqual(artists, expected) {
+		t.Errorf("expected artists %q were not matched: %q", expected, artists)
+	}
+}
+
+func TestArtistsMissingFile(t *testing.T) {
+	if _, err := repositories.NewArtistRepository("testdata/artists/missing.txt").Artists(); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+}
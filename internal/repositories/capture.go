@@ -0,0 +1,528 @@
+package repositories
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+// EXIF tags read from the image metadata
+const (
+	exifTagDateTime           = 0x0132
+	exifTagDateTimeOriginal   = 0x9003
+	exifTagExifIFD            = 0x8769
+	exifTagGPSIFD             = 0x8825
+	exifTagGPSLatitude        = 0x0002
+	exifTagGPSLatitudeRef     = 0x0001
+	exifTagGPSLongitude       = 0x0004
+	exifTagGPSLongitudeRef    = 0x0003
+	exifTagModel              = 0x0110
+	exifTagOffsetTimeOriginal = 0x9011
+)
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	jpegHeader = []byte{0xff, 0xd8}
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+	// sizes of each of the EXIF value types in bytes
+	exifTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+	xmpDate      = regexp.MustCompile(`(?:exif:DateTimeOriginal|photoshop:DateCreated|xmp:CreateDate)(?:>|=")([^<"]+)`)
+	xmpLatitude  = regexp.MustCompile(`exif:GPSLatitude(?:>|=")([^<"]+)`)
+	xmpLongitude = regexp.MustCompile(`exif:GPSLongitude(?:>|=")([^<"]+)`)
+	xmpModel     = regexp.MustCompile(`tiff:Model(?:>|=")([^<"]+)`)
+	xmpTimes     = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02",
+	}
+)
+
+// capture is the time, device and location an image was captured as
+// recorded in its metadata
+type capture struct {
+	device   string
+	location *models.Location
+	taken    time.Time
+}
+
+type exifEntry struct {
+	count uint32
+	kind  uint16
+	value []byte
+}
+
+// readMetadata reads the start of a JPEG or PNG image up to its image
+// data, following the length of each segment or chunk so metadata of
+// any size is read in full
+func readMetadata(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+
+	// next reads n more bytes into the buffer and returns them
+	next := func(n int64) ([]byte, error) {
+		start := buf.Len()
+		_, err := io.CopyN(&buf, r, n)
+		return buf.Bytes()[start:], err
+	}
+
+	_, err := next(int64(len(jpegHeader)))
+	if err == nil && bytes.Equal(buf.Bytes(), jpegHeader) {
+		err = readJPEGSegments(next)
+	} else if err == nil {
+		_, err = next(int64(len(pngHeader) - len(jpegHeader)))
+		if err == nil && bytes.Equal(buf.Bytes(), pngHeader) {
+			err = readPNGChunks(next)
+		}
+	}
+
+	// images cut off before the image data still have their metadata read
+	if err == io.EOF {
+		err = nil
+	}
+
+	return buf.Bytes(), err
+}
+
+// readJPEGSegments reads each segment up to the start of scan segment
+// the image data follows
+func readJPEGSegments(next func(n int64) ([]byte, error)) error {
+	for {
+		b, err := next(4)
+		if err != nil {
+			return err
+		}
+
+		if b[0] != 0xff || b[1] == 0xda {
+			return nil
+		}
+
+		size := int64(binary.BigEndian.Uint16(b[2:4]))
+		if size < 2 {
+			return nil
+		}
+
+		if _, err := next(size - 2); err != nil {
+			return err
+		}
+	}
+}
+
+// readPNGChunks reads each chunk, along with its CRC, up to the first
+// chunk of image data
+func readPNGChunks(next func(n int64) ([]byte, error)) error {
+	for {
+		b, err := next(8)
+		if err != nil {
+			return err
+		}
+
+		switch string(b[4:8]) {
+		case "IDAT", "IEND":
+			return nil
+		}
+
+		if _, err := next(int64(binary.BigEndian.Uint32(b[0:4])) + 4); err != nil {
+			return err
+		}
+	}
+}
+
+// readCapture reads the EXIF and XMP metadata embedded in a JPEG or
+// PNG image, along with the PNG text chunks screenshots commonly use
+func readCapture(data []byte) capture {
+	switch {
+	case bytes.HasPrefix(data, pngHeader):
+		return readPNGCapture(data[len(pngHeader):])
+	case bytes.HasPrefix(data, jpegHeader):
+		return readJPEGCapture(data[len(jpegHeader):])
+	}
+
+	return capture{}
+}
+
+func readJPEGCapture(data []byte) capture {
+	c := capture{}
+
+	for len(data) >= 4 && data[0] == 0xff {
+		marker := data[1]
+
+		// the image data follows the start of scan segment
+		if marker == 0xda {
+			break
+		}
+
+		size := int(binary.BigEndian.Uint16(data[2:4]))
+		if size < 2 || len(data) < size+2 {
+			break
+		}
+
+		segment := data[4 : size+2]
+		if marker == 0xe1 {
+			switch {
+			case bytes.HasPrefix(segment, exifHeader):
+				c.merge(readEXIF(segment[len(exifHeader):]))
+			case bytes.HasPrefix(segment, xmpHeader):
+				c.merge(readXMP(string(segment[len(xmpHeader):])))
+			}
+		}
+
+		data = data[size+2:]
+	}
+
+	return c
+}
+
+func readPNGCapture(data []byte) capture {
+	c := capture{}
+
+	for len(data) >= 12 {
+		size := binary.BigEndian.Uint32(data[0:4])
+		if uint64(len(data)) < uint64(size)+12 {
+			break
+		}
+
+		kind := string(data[4:8])
+		chunk := data[8 : 8+size]
+
+		switch kind {
+		case "eXIf":
+			c.merge(readEXIF(chunk))
+		case "iTXt", "tEXt", "zTXt":
+			keyword, text := readPNGText(kind, chunk)
+			switch keyword {
+			case "Creation Time":
+				if c.taken.IsZero() {
+					c.taken = parseXMPTime(text)
+				}
+			case "XML:com.adobe.xmp":
+				c.merge(readXMP(text))
+			}
+		case "IEND":
+			return c
+		}
+
+		data = data[12+size:]
+	}
+
+	return c
+}
+
+// readPNGText returns the keyword and text of a tEXt, zTXt or iTXt chunk
+func readPNGText(kind string, chunk []byte) (string, string) {
+	i := bytes.IndexByte(chunk, 0)
+	if i < 0 {
+		return "", ""
+	}
+
+	keyword, rest := string(chunk[:i]), chunk[i+1:]
+
+	switch kind {
+	case "tEXt":
+		return keyword, string(rest)
+	case "zTXt":
+		if len(rest) < 1 {
+			return keyword, ""
+		}
+
+		return keyword, inflate(rest[1:])
+	}
+
+	// iTXt has compression flag and method bytes followed by the
+	// language tag and translated keyword before the text
+	if len(rest) < 2 {
+		return keyword, ""
+	}
+
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	for n := 0; n < 2; n++ {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return keyword, ""
+		}
+
+		rest = rest[i+1:]
+	}
+
+	if compressed {
+		return keyword, inflate(rest)
+	}
+
+	return keyword, string(rest)
+}
+
+// readEXIF reads the capture time, device model and GPS position from
+// the TIFF structured EXIF data
+func readEXIF(data []byte) capture {
+	c := capture{}
+
+	if len(data) < 8 {
+		return c
+	}
+
+	var bo binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return c
+	}
+
+	ifd0 := readIFD(data, bo, bo.Uint32(data[4:8]))
+	if e, ok := ifd0[exifTagModel]; ok {
+		c.device = exifString(e)
+	}
+
+	taken := ""
+	if e, ok := ifd0[exifTagDateTime]; ok {
+		taken = exifString(e)
+	}
+
+	offset := ""
+	if e, ok := ifd0[exifTagExifIFD]; ok && len(e.value) >= 4 {
+		exif := readIFD(data, bo, bo.Uint32(e.value))
+		if e, ok := exif[exifTagDateTimeOriginal]; ok {
+			taken = exifString(e)
+		}
+
+		if e, ok := exif[exifTagOffsetTimeOriginal]; ok {
+			offset = exifString(e)
+		}
+	}
+
+	c.taken = parseEXIFTime(taken, offset)
+
+	if e, ok := ifd0[exifTagGPSIFD]; ok && len(e.value) >= 4 {
+		gps := readIFD(data, bo, bo.Uint32(e.value))
+		lat, latOK := exifCoordinate(gps[exifTagGPSLatitude], gps[exifTagGPSLatitudeRef], bo)
+		lon, lonOK := exifCoordinate(gps[exifTagGPSLongitude], gps[exifTagGPSLongitudeRef], bo)
+		if latOK && lonOK {
+			c.location = &models.Location{Latitude: lat, Longitude: lon}
+		}
+	}
+
+	return c
+}
+
+// readIFD reads the entries of the image file directory at the offset,
+// ignoring any entry that falls outside of the data
+func readIFD(data []byte, bo binary.ByteOrder, offset uint32) map[uint16]exifEntry {
+	entries := map[uint16]exifEntry{}
+
+	if uint64(offset)+2 > uint64(len(data)) {
+		return entries
+	}
+
+	count := int(bo.Uint16(data[offset:]))
+	for i := 0; i < count; i++ {
+		start := uint64(offset) + 2 + uint64(i)*12
+		if start+12 > uint64(len(data)) {
+			break
+		}
+
+		e := data[start : start+12]
+		entry := exifEntry{
+			count: bo.Uint32(e[4:8]),
+			kind:  bo.Uint16(e[2:4]),
+		}
+
+		size, ok := exifTypeSizes[entry.kind]
+		if !ok {
+			continue
+		}
+
+		length := uint64(size) * uint64(entry.count)
+		if length <= 4 {
+			entry.value = e[8 : 8+length]
+		} else {
+			at := uint64(bo.Uint32(e[8:12]))
+			if at+length > uint64(len(data)) {
+				continue
+			}
+
+			entry.value = data[at : at+length]
+		}
+
+		entries[bo.Uint16(e[0:2])] = entry
+	}
+
+	return entries
+}
+
+// readXMP reads the capture time, device model and GPS position from
+// an XMP packet
+func readXMP(xmp string) capture {
+	c := capture{}
+
+	if m := xmpDate.FindStringSubmatch(xmp); m != nil {
+		c.taken = parseXMPTime(m[1])
+	}
+
+	if m := xmpModel.FindStringSubmatch(xmp); m != nil {
+		c.device = strings.TrimSpace(m[1])
+	}
+
+	lat := xmpLatitude.FindStringSubmatch(xmp)
+	lon := xmpLongitude.FindStringSubmatch(xmp)
+	if lat != nil && lon != nil {
+		latitude, latOK := parseXMPCoordinate(lat[1])
+		longitude, lonOK := parseXMPCoordinate(lon[1])
+		if latOK && lonOK {
+			c.location = &models.Location{Latitude: latitude, Longitude: longitude}
+		}
+	}
+
+	return c
+}
+
+// merge fills the details missing from the capture with those read
+// from another source of metadata in the same image
+func (c *capture) merge(o capture) {
+	if c.device == "" {
+		c.device = o.device
+	}
+
+	if c.location == nil {
+		c.location = o.location
+	}
+
+	if c.taken.IsZero() {
+		c.taken = o.taken
+	}
+}
+
+func exifCoordinate(value exifEntry, ref exifEntry, bo binary.ByteOrder) (float64, bool) {
+	if value.kind != 5 || value.count != 3 {
+		return 0, false
+	}
+
+	coordinate := 0.0
+	for i, div := range []float64{1, 60, 3600} {
+		num := bo.Uint32(value.value[i*8:])
+		den := bo.Uint32(value.value[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+
+		coordinate += float64(num) / float64(den) / div
+	}
+
+	if r := exifString(ref); r == "S" || r == "W" {
+		coordinate = -coordinate
+	}
+
+	return coordinate, true
+}
+
+func exifString(e exifEntry) string {
+	if e.kind != 2 {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+func inflate(data []byte) string {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
+
+// parseEXIFTime parses the EXIF date and time, which is recorded in
+// the local time of the device unless an offset is also recorded
+func parseEXIFTime(value string, offset string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return t
+		}
+	}
+
+	t, err := time.ParseInLocation(exifTimeLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// parseXMPCoordinate parses a coordinate in the XMP "DDD,MM.mmk" or
+// "DDD,MM,SSk" format where k is the direction
+func parseXMPCoordinate(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return 0, false
+	}
+
+	direction := value[len(value)-1]
+	parts := strings.Split(value[:len(value)-1], ",")
+
+	coordinate := 0.0
+	for i, p := range parts {
+		if i > 2 {
+			return 0, false
+		}
+
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		coordinate += f / math.Pow(60, float64(i))
+	}
+
+	if direction == 'S' || direction == 'W' {
+		coordinate = -coordinate
+	}
+
+	return coordinate, true
+}
+
+func parseXMPTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range xmpTimes {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t
+		}
+	}
+
+	// PNG creation times are commonly written in RFC 1123 format
+	if t, err := time.Parse(time.RFC1123Z, value); err == nil {
+		return t
+	}
+
+	if t, err := time.ParseInLocation(exifTimeLayout, value, time.Local); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
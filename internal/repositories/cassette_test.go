@@ -0,0 +1,190 @@
+package repositories_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// cassetteRecordEnv names the environment variable that, when set,
+// records cassettes from the live API in place of replaying them
+const cassetteRecordEnv = "SONG_FINDER_RECORD"
+
+// interaction is a request made to an HTTP API and the response
+// recorded for it, where bodies are kept as JSON when they are JSON
+// so the cassettes can be read and edited by hand
+type interaction struct {
+	Body        json.RawMessage `json:",omitempty"`
+	BodyText    string          `json:",omitempty"`
+	Method      string
+	Request     json.RawMessage `json:",omitempty"`
+	RequestText string          `json:",omitempty"`
+	Status      int
+	URL         string
+}
+
+// cassette is an http.RoundTripper that replays the responses recorded
+// in a file for each request, or records them with the live transport
+type cassette struct {
+	Interactions []*interaction
+	lock         sync.Mutex
+	path         string
+	played       map[*interaction]bool
+	transport    http.RoundTripper
+}
+
+// newCassette returns a cassette replaying the interactions recorded
+// in testdata, failing the test when a recorded request isn't made.
+// Setting SONG_FINDER_RECORD records the interactions from the live
+// API in place of replaying them
+func newCassette(t *testing.T, name string) *cassette {
+	c := &cassette{
+		path:   filepath.Join("testdata", "spotify", name+".json"),
+		played: map[*interaction]bool{},
+	}
+
+	if os.Getenv(cassetteRecordEnv) != "" {
+		c.transport = http.DefaultTransport
+		return c
+	}
+
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(data, &c.Interactions); err != nil {
+		t.Fatalf("invalid cassette %s: %v", c.path, err)
+	}
+
+	// request bodies are compared as sent, without indentation
+	for _, i := range c.Interactions {
+		setBody(&i.Request, &i.RequestText, i.Request)
+	}
+
+	t.Cleanup(func() {
+		for _, i := range c.Interactions {
+			if !c.played[i] {
+				t.Errorf("expected the recorded request %s %s", i.Method, i.URL)
+			}
+		}
+	})
+
+	return c
+}
+
+// recording returns true when the cassette records the live API
+func (c *cassette) recording() bool {
+	return c.transport != nil
+}
+
+// RoundTrip replays the first response recorded for a request with the
+// same method, URL and body that has not been replayed, or records the
+// response from the live transport
+func (c *cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.recording() {
+		return c.record(req, body)
+	}
+
+	requested := &interaction{Method: req.Method, URL: req.URL.String()}
+	setBody(&requested.Request, &requested.RequestText, body)
+
+	for _, i := range c.Interactions {
+		if c.played[i] || i.Method != requested.Method || i.URL != requested.URL ||
+			!bytes.Equal(i.Request, requested.Request) || i.RequestText != requested.RequestText {
+			continue
+		}
+
+		c.played[i] = true
+
+		res := &http.Response{
+			Body:       ioutil.NopCloser(bytes.NewReader(append(i.Body, i.BodyText...))),
+			Header:     http.Header{},
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Request:    req,
+			Status:     fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+			StatusCode: i.Status,
+		}
+
+		if len(i.Body) > 0 {
+			res.Header.Set("Content-Type", "application/json")
+		}
+
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("no recorded response for %s %s %s%s", req.Method, req.URL, requested.Request, requested.RequestText)
+}
+
+// record sends the request with the live transport and writes each
+// interaction recorded so far to the cassette
+func (c *cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	res, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	i := &interaction{Method: req.Method, Status: res.StatusCode, URL: req.URL.String()}
+	setBody(&i.Request, &i.RequestText, body)
+	setBody(&i.Body, &i.BodyText, b)
+	c.Interactions = append(c.Interactions, i)
+
+	data, err := json.MarshalIndent(c.Interactions, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return nil, err
+	}
+
+	return res, ioutil.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+// setBody keeps the body as JSON when it is JSON and as text otherwise
+func setBody(raw *json.RawMessage, text *string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	if json.Valid(body) {
+		var b bytes.Buffer
+		if err := json.Compact(&b, body); err == nil {
+			*raw = b.Bytes()
+			return
+		}
+	}
+
+	*text = string(body)
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+// files of each fixture, which is a directory named after the fixture
+const (
+	corpusAnnotationFile = "annotation.txt"
+	corpusExpectedFile   = "expected.json"
+	corpusLayoutFile     = "layout.json"
+	corpusResponsesFile  = "responses.json"
+)
+
+type corpusExpected struct {
+	Artist string `json:"artist"`
+	Source string `json:"source"`
+	Term   string `json:"term,omitempty"`
+	Title  string `json:"title"`
+}
+
+type corpusRepository struct {
+	dir string
+}
+
+// NewCorpusRepository returns an instance of ICorpusRepository for
+// the fixtures in the directory, each of which is a directory with the
+// annotation text, the expected artist, title and source as JSON and
+// optionally the layout detected in the screenshot and the responses
+// recorded from the music provider as JSON
+func NewCorpusRepository(dir string) interfaces.ICorpusRepository {
+	return &corpusRepository{
+		dir: dir,
+	}
+}
+
+// Add writes the fixture to the corpus, failing when a fixture of the
+// same name already exists
+func (r *corpusRepository) Add(fixture models.Fixture) error {
+	dir := filepath.Join(r.dir, fixture.Name)
+
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("fixture %q already exists in %s", fixture.Name, r.dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, corpusAnnotationFile), []byte(fixture.Annotation), 0644); err != nil {
+		return err
+	}
+
+	expected, err := json.MarshalIndent(corpusExpected{
+		Artist: fixture.Artist,
+		Source: fixture.Source,
+		Term:   fixture.Term,
+		Title:  fixture.Title,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, corpusExpectedFile), append(expected, '\n'), 0644); err != nil {
+		return err
+	}
+
+	if len(fixture.Layout) > 0 {
+		if err := ioutil.WriteFile(filepath.Join(dir, corpusLayoutFile), fixture.Layout, 0644); err != nil {
+			return err
+		}
+	}
+
+	if fixture.Responses.Empty() {
+		return nil
+	}
+
+	responses, err := json.MarshalIndent(fixture.Responses, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, corpusResponsesFile), append(responses, '\n'), 0644)
+}
+
+// Fixtures returns the fixtures in the corpus ordered by name
+func (r *corpusRepository) Fixtures() ([]models.Fixture, error) {
+	entries, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := []models.Fixture{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		f, err := r.fixture(e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		fixtures = append(fixtures, f)
+	}
+
+	sort.Slice(fixtures, func(i, j int) bool {
+		return fixtures[i].Name < fixtures[j].Name
+	})
+
+	return fixtures, nil
+}
+
+func (r *corpusRepository) fixture(name string) (models.Fixture, error) {
+	dir := filepath.Join(r.dir, name)
+
+	annotation, err := ioutil.ReadFile(filepath.Join(dir, corpusAnnotationFile))
+	if err != nil {
+		return models.Fixture{}, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, corpusExpectedFile))
+	if err != nil {
+		return models.Fixture{}, err
+	}
+
+	expected := corpusExpected{}
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return models.Fixture{}, fmt.Errorf("invalid %s for fixture %q: %v", corpusExpectedFile, name, err)
+	}
+
+	layout, err := ioutil.ReadFile(filepath.Join(dir, corpusLayoutFile))
+	if err != nil && !os.IsNotExist(err) {
+		return models.Fixture{}, err
+	}
+
+	responses := models.RecordedResponses{}
+	data, err = ioutil.ReadFile(filepath.Join(dir, corpusResponsesFile))
+	if err != nil && !os.IsNotExist(err) {
+		return models.Fixture{}, err
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, &responses); err != nil {
+			return models.Fixture{}, fmt.Errorf("invalid %s for fixture %q: %v", corpusResponsesFile, name, err)
+		}
+	}
+
+	return models.Fixture{
+		Annotation: string(annotation),
+		Artist:     expected.Artist,
+		Layout:     layout,
+		Name:       name,
+		Responses:  responses,
+		Source:     expected.Source,
+		Term:       expected.Term,
+		Title:      expected.Title,
+	}, nil
+}
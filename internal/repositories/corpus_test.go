@@ -0,0 +1,75 @@
+package repositories_test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/repositories"
+)
+
+func TestCorpusFixtures(t *testing.T) {
+	fixtures, err := repositories.NewCorpusRepository("testdata/corpus").Fixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures) != 1 {
+		t.Fatalf("expected a single fixture: %+v", fixtures)
+	}
+
+	f := fixtures[0]
+	if f.Name != "shazam-layout" || f.Artist != "Raphael Lake, Eric Brooks, Camden Rose" || f.Source != "Shazam" || f.Term != "" {
+		t.Errorf("expected the fixture to be read from the corpus: %+v", f)
+	}
+
+	if len(f.Layout) == 0 {
+		t.Error("expected the layout to be read from the corpus")
+	}
+}
+
+func TestCorpusAdd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cr := repositories.NewCorpusRepository(dir)
+	f := models.Fixture{
+		Annotation: "The Dig - Soul of the Night\n",
+		Artist:     "The Dig",
+		Name:       "divider",
+		Responses: models.RecordedResponses{
+			Search: map[string][]models.Track{
+				"the dig soul of the night": {{
+					Artists: []string{"The Dig"},
+					Name:    "Soul of the Night",
+					URI:     "spotify:track:2rfmgGv5Ws1VZnXcsFHGVK",
+				}},
+			},
+		},
+		Source: "Other",
+		Term:   "the dig soul of the night",
+		Title:  "Soul of the Night",
+	}
+
+	if err := cr.Add(f); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := cr.Fixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures) != 1 || !reflect.DeepEqual(fixtures[0], f) {
+		t.Errorf("expected the fixture %+v to be read back: %+v", f, fixtures)
+	}
+
+	if err := cr.Add(f); err == nil {
+		t.Error("expected an error adding a fixture that already exists")
+	}
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	musicBrainzBaseURL   = "https://musicbrainz.org/ws/2"
+	musicBrainzMinScore  = 90
+	musicBrainzRateLimit = time.Second
+	musicBrainzUserAgent = "song-finder ( https://github.com/brozeph/song-finder )"
+)
+
+// escape the Lucene special characters in MusicBrainz search queries
+var luceneEscaper = strings.NewReplacer(
+	`\`, `\\`, `+`, `\+`, `-`, `\-`, `!`, `\!`, `(`, `\(`, `)`, `\)`,
+	`{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`, `^`, `\^`, `"`, `\"`,
+	`~`, `\~`, `*`, `\*`, `?`, `\?`, `:`, `\:`, `/`, `\/`, `&`, `\&`,
+	`|`, `\|`)
+
+// MusicBrainzOptions contains the settings for the MusicBrainz API
+type MusicBrainzOptions struct {
+	// BaseURL of the MusicBrainz WS/2 API, which defaults to
+	// https://musicbrainz.org/ws/2
+	BaseURL string
+	// MinScore a recording must have to be considered a match,
+	// which defaults to 90
+	MinScore int
+	// RateLimit is the minimum time between requests, which
+	// defaults to the 1 request per second MusicBrainz allows
+	RateLimit time.Duration
+}
+
+type musicBrainzRepository struct {
+	cache       map[string]*models.Recording
+	client      *http.Client
+	lastRequest time.Time
+	lock        sync.Mutex
+	options     MusicBrainzOptions
+}
+
+type musicBrainzRecordingSearch struct {
+	Recordings []struct {
+		ArtistCredit []struct {
+			Name string `json:"name"`
+		} `json:"artist-credit"`
+		ID    string   `json:"id"`
+		ISRCs []string `json:"isrcs"`
+		Score int      `json:"score"`
+		Title string   `json:"title"`
+	} `json:"recordings"`
+}
+
+// NewMusicBrainzRepository returns a new instance
+func NewMusicBrainzRepository(options MusicBrainzOptions) interfaces.IMetadataRepository {
+	if options.BaseURL == "" {
+		options.BaseURL = musicBrainzBaseURL
+	}
+
+	if options.MinScore == 0 {
+		options.MinScore = musicBrainzMinScore
+	}
+
+	if options.RateLimit == 0 {
+		options.RateLimit = musicBrainzRateLimit
+	}
+
+	return &musicBrainzRepository{
+		cache:   map[string]*models.Recording{},
+		client:  &http.Client{Timeout: 30 * time.Second},
+		options: options,
+	}
+}
+
+// FindRecording searches MusicBrainz for the recording best matching
+// the search term and returns nil when no recording scores high enough
+func (r *musicBrainzRepository) FindRecording(searchTerm string) (*models.Recording, error) {
+	if len(searchTerm) == 0 {
+		return nil, nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if rec, ok := r.cache[searchTerm]; ok {
+		return rec, nil
+	}
+
+	// honor the MusicBrainz rate limit
+	if wait := r.options.RateLimit - time.Since(r.lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	r.lastRequest = time.Now()
+
+	q := url.Values{}
+	q.Set("fmt", "json")
+	q.Set("limit", "1")
+	q.Set("query", luceneEscaper.Replace(searchTerm))
+
+	req, err := http.NewRequest(http.MethodGet, r.options.BaseURL+"/recording?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", musicBrainzUserAgent)
+
+	log.Debug().Str("song", searchTerm).Msg("searching MusicBrainz for recording")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("musicbrainz: recording search: %s", res.Status)
+	}
+
+	mrs := musicBrainzRecordingSearch{}
+	if err := json.NewDecoder(res.Body).Decode(&mrs); err != nil {
+		return nil, err
+	}
+
+	var rec *models.Recording
+
+	if len(mrs.Recordings) > 0 && mrs.Recordings[0].Score >= r.options.MinScore {
+		mr := mrs.Recordings[0]
+		rec = &models.Recording{
+			ID:    mr.ID,
+			ISRCs: mr.ISRCs,
+			Score: mr.Score,
+			Title: mr.Title,
+		}
+
+		for _, a := range mr.ArtistCredit {
+			rec.Artists = append(rec.Artists, a.Name)
+		}
+	}
+
+	r.cache[searchTerm] = rec
+
+	return rec, nil
+}
@@ -0,0 +1,121 @@
+package repositories_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/repositories"
+)
+
+// newMusicBrainzMock returns a local mock of the MusicBrainz WS/2 JSON API
+// serving the recorded fixture for each query and counting the requests
+func newMusicBrainzMock(t *testing.T, queries map[string]string, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		if r.URL.Path != "/recording" || r.FormValue("fmt") != "json" {
+			t.Errorf("unexpected request %s", r.URL.RequestURI())
+		}
+
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "song-finder") {
+			t.Errorf("expected a song-finder User-Agent: %s", r.Header.Get("User-Agent"))
+		}
+
+		fixture, ok := queries[r.FormValue("query")]
+		if !ok {
+			t.Errorf("unexpected query %s", r.FormValue("query"))
+			http.NotFound(w, r)
+			return
+		}
+
+		serveFixture(t, w, "musicbrainz", fixture)
+	}))
+}
+
+func TestMusicBrainzFindRecording(t *testing.T) {
+	requests := 0
+	srv := newMusicBrainzMock(t, map[string]string{
+		`reverend freakchild personal jesus \(on the...`: "recording-search.json",
+	}, &requests)
+	defer srv.Close()
+
+	mbr := repositories.NewMusicBrainzRepository(repositories.MusicBrainzOptions{
+		BaseURL:   srv.URL,
+		RateLimit: time.Millisecond,
+	})
+
+	rec, err := mbr.FindRecording("reverend freakchild personal jesus (on the...")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec == nil ||
+		rec.Title != "Personal Jesus" ||
+		len(rec.Artists) != 1 ||
+		rec.Artists[0] != "Reverend Freakchild" ||
+		len(rec.ISRCs) != 1 ||
+		rec.ISRCs[0] != "USA2P1900123" {
+		t.Errorf("unexpected recording: %+v", rec)
+	}
+}
+
+func TestMusicBrainzFindRecordingBelowMinScore(t *testing.T) {
+	requests := 0
+	srv := newMusicBrainzMock(t, map[string]string{
+		"porntland radis pryeat": "recording-search-low-score.json",
+	}, &requests)
+	defer srv.Close()
+
+	mbr := repositories.NewMusicBrainzRepository(repositories.MusicBrainzOptions{
+		BaseURL:   srv.URL,
+		RateLimit: time.Millisecond,
+	})
+
+	rec, err := mbr.FindRecording("porntland radis pryeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec != nil {
+		t.Errorf("expected no recording for a low score: %+v", rec)
+	}
+}
+
+func TestMusicBrainzRateLimitAndCache(t *testing.T) {
+	requests := 0
+	srv := newMusicBrainzMock(t, map[string]string{
+		"the dig soul of the night": "recording-search.json",
+		"porntland radis pryeat":    "recording-search-low-score.json",
+	}, &requests)
+	defer srv.Close()
+
+	rateLimit := 250 * time.Millisecond
+	mbr := repositories.NewMusicBrainzRepository(repositories.MusicBrainzOptions{
+		BaseURL:   srv.URL,
+		RateLimit: rateLimit,
+	})
+
+	start := time.Now()
+
+	for _, term := range []string{
+		"the dig soul of the night",
+		"porntland radis pryeat",
+		"the dig soul of the night",
+		"porntland radis pryeat",
+	} {
+		if _, err := mbr.FindRecording(term); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("expected cached results to be reused: %d requests", requests)
+	}
+
+	if elapsed := time.Since(start); elapsed < rateLimit {
+		t.Errorf("expected requests to be spaced by the rate limit: %s", elapsed)
+	}
+}
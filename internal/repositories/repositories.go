@@ -1 +1,15 @@
 package repositories
+
+import "github.com/brozeph/song-finder/internal/models"
+
+const defaultPlaylistDescription = "Playlist created by song-finder using image detection of screenshots"
+
+// playlistDescription returns the description supplied in the options
+// or the default description when none is supplied
+func playlistDescription(opts models.PlaylistOptions) string {
+	if opts.Description != "" {
+		return opts.Description
+	}
+
+	return defaultPlaylistDescription
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,15 +14,70 @@ import (
 	"github.com/brozeph/song-finder/internal/interfaces"
 	"github.com/brozeph/song-finder/internal/models"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/api/option"
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
 )
 
 var imageExtensions = []string{".png", ".jpg", ".jpeg"}
 
-type screenshotRepository struct{}
+// ScreenshotOptions contains the settings for detecting text in
+// screenshots with the Google Cloud vision API
+type ScreenshotOptions struct {
+	// ClientOptions for the Vision client, such as the endpoint of a
+	// fake Vision backend in tests
+	ClientOptions []option.ClientOption
+	// LanguageHints are the BCP-47 codes of the languages expected in
+	// the screenshots, which Vision detects automatically when empty
+	LanguageHints []string
+}
+
+type screenshotRepository struct {
+	options ScreenshotOptions
+}
 
 // NewScreenshotRepository returns a new instance
-func NewScreenshotRepository() interfaces.IScreenshotRepository {
-	return &screenshotRepository{}
+func NewScreenshotRepository(options ScreenshotOptions) interfaces.IScreenshotRepository {
+	return &screenshotRepository{
+		options: options,
+	}
+}
+
+// DetectLabels accepts an image path, reads the image and requests
+// the labels describing its content from the Google Cloud vision API
+func (sr *screenshotRepository) DetectLabels(path string) ([]models.Label, error) {
+	ctx := context.Background()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ifr, err := vision.NewImageFromReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := vision.NewImageAnnotatorClient(ctx, sr.options.ClientOptions...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	annotations, err := client.DetectLabels(ctx, ifr, nil, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]models.Label, 0, len(annotations))
+	for _, a := range annotations {
+		labels = append(labels, models.Label{
+			Description: a.Description,
+			Score:       float64(a.Score),
+		})
+	}
+
+	return labels, nil
 }
 
 // DetectText accepts an image path, reads the image and
@@ -35,6 +91,7 @@ func (sr *screenshotRepository) DetectText(path string) (string, error) {
 	if err != nil {
 		return text, err
 	}
+	defer f.Close()
 
 	ifr, err := vision.NewImageFromReader(f)
 	if err != nil {
@@ -42,62 +99,112 @@ func (sr *screenshotRepository) DetectText(path string) (string, error) {
 	}
 
 	// for each image, upload to Google image analysis
-	client, err := vision.NewImageAnnotatorClient(ctx)
+	client, err := vision.NewImageAnnotatorClient(ctx, sr.options.ClientOptions...)
 	if err != nil {
 		return text, err
 	}
+	defer client.Close()
+
+	var ictx *pb.ImageContext
+	if len(sr.options.LanguageHints) > 0 {
+		ictx = &pb.ImageContext{LanguageHints: sr.options.LanguageHints}
+	}
 
-	annotations, err := client.DetectTexts(ctx, ifr, nil, 10)
+	annotations, err := client.DetectTexts(ctx, ifr, ictx, 10)
 	if err != nil {
 		return text, err
 	}
 
-	if annotations[0] != nil {
+	// no annotations are returned for an image without text
+	if len(annotations) > 0 && annotations[0] != nil {
 		text = annotations[0].Description
 	}
 
 	return text, nil
 }
 
-func (sr *screenshotRepository) FindInPath(path string) ([]*models.Screenshot, error) {
-	var (
-		h  = sha256.New()
-		sf []*models.Screenshot
-	)
-
-	// find all of the image files
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		if isImage(path) && info.Size() > 0 {
-			// reset hash
-			h.Reset()
-
-			// determine shasum
-			f, err := os.Open(path)
-			if err != nil {
-				return nil
-			}
+// FindInPath finds the images in the path allowed by the discovery
+// options and reads the time, device and location each was captured
+// from the image metadata, falling back to the time the file was last
+// modified
+func (sr *screenshotRepository) FindInPath(path string, opts models.DiscoveryOptions) ([]*models.Screenshot, error) {
+	var sf []*models.Screenshot
 
-			defer f.Close()
-			if _, err := io.Copy(h, f); err != nil {
-				log.Error().Stack().Err(err).Msg("unable to calculate sha sum")
-			}
-
-			sf = append(sf, &models.Screenshot{
-				Path:   path,
-				SHASum: hex.EncodeToString(h.Sum(nil)),
-			})
+	add := func(path string, info os.FileInfo) {
+		ss, err := readScreenshot(path, info, opts)
+		if err != nil {
+			log.Error().Stack().Err(err).Str("path", path).Msg("unable to read image")
+			return
 		}
 
-		return nil
-	})
+		if ss != nil {
+			sf = append(sf, ss)
+		}
+	}
 
+	root := filepath.Clean(path)
+	info, err := os.Stat(root)
 	if err != nil {
 		return nil, err
 	}
 
+	// a single image may be supplied in place of a directory
+	if !info.IsDir() {
+		if isImage(root) && info.Size() > 0 {
+			add(root, info)
+		}
+
+		return sf, nil
+	}
+
+	if err := walkImages(root, root, 1, opts, map[string]bool{}, add); err != nil {
+		return nil, err
+	}
+
 	return sf, nil
 }
 
+// readScreenshot reads the metadata from the start of the image and,
+// when it was captured in the range of the discovery options, streams
+// the rest of the image to determine its shasum
+func readScreenshot(path string, info os.FileInfo, opts models.DiscoveryOptions) (*models.Screenshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header, err := readMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+
+	c := readCapture(header)
+	if c.taken.IsZero() {
+		c.taken = info.ModTime()
+	}
+
+	if (!opts.Since.IsZero() && c.taken.Before(opts.Since)) ||
+		(!opts.Until.IsZero() && c.taken.After(opts.Until)) {
+		return nil, nil
+	}
+
+	// determine shasum
+	h := sha256.New()
+	h.Write(header)
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return &models.Screenshot{
+		Captured: c.taken,
+		Device:   c.device,
+		Location: c.location,
+		Path:     path,
+		SHASum:   hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
 func isImage(filePath string) bool {
 	var fileExt = strings.ToLower(filepath.Ext(filePath))
 
@@ -109,3 +216,94 @@ func isImage(filePath string) bool {
 
 	return false
 }
+
+// matchesAny returns true when the base name or the path relative to
+// the root of the scan matches one of the glob patterns
+func matchesAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, filepath.Base(rel)); ok {
+			return true
+		}
+
+		if ok, _ := filepath.Match(p, filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walkImages calls fn for each image in the directory and, up to the
+// maximum depth, its subdirectories. Symbolic links are followed when
+// the options allow, visiting each directory only once
+func walkImages(
+	root string,
+	dir string,
+	depth int,
+	opts models.DiscoveryOptions,
+	visited map[string]bool,
+	fn func(path string, info os.FileInfo)) error {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	if visited[resolved] {
+		return nil
+	}
+
+	visited[resolved] = true
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range entries {
+		path := filepath.Join(dir, info.Name())
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !opts.FollowSymlinks {
+				continue
+			}
+
+			if info, err = os.Stat(path); err != nil {
+				log.Debug().Err(err).Str("path", path).Msg("unable to follow symbolic link")
+				continue
+			}
+		}
+
+		if matchesAny(opts.Exclude, rel) {
+			continue
+		}
+
+		if info.IsDir() {
+			if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+				continue
+			}
+
+			if err := walkImages(root, path, depth+1, opts, visited, fn); err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("unable to read directory")
+			}
+
+			continue
+		}
+
+		if !isImage(path) || info.Size() == 0 {
+			continue
+		}
+
+		if len(opts.Include) > 0 && !matchesAny(opts.Include, rel) {
+			continue
+		}
+
+		fn(path, info)
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package repositories_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/repositories"
+)
+
+func findScreenshots(t *testing.T, path string, opts models.DiscoveryOptions) map[string]*models.Screenshot {
+	sss, err := repositories.NewScreenshotRepository(repositories.ScreenshotOptions{}).FindInPath(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]*models.Screenshot{}
+	for _, s := range sss {
+		rel, err := filepath.Rel(path, s.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found[filepath.ToSlash(rel)] = s
+	}
+
+	return found
+}
+
+func TestFindInPathReadsEXIF(t *testing.T) {
+	s := findScreenshots(t, filepath.Join("testdata", "screenshots"), models.DiscoveryOptions{})["exif.jpg"]
+	if s == nil {
+		t.Fatal("expected the JPEG screenshot to be found")
+	}
+
+	if expected := time.Date(2021, 3, 14, 22, 9, 26, 0, time.UTC); !s.Captured.Equal(expected) {
+		t.Errorf("expected the original capture time %s: %s", expected, s.Captured)
+	}
+
+	if s.Device != "iPhone 12 Pro" {
+		t.Errorf("expected the device model: %q", s.Device)
+	}
+
+	if s.Location == nil ||
+		math.Abs(s.Location.Latitude-47.6083) > 0.0001 ||
+		math.Abs(s.Location.Longitude+122.3267) > 0.0001 {
+		t.Errorf("expected the GPS location: %+v", s.Location)
+	}
+}
+
+func TestFindInPathReadsPNGMetadata(t *testing.T) {
+	found := findScreenshots(t, filepath.Join("testdata", "screenshots"), models.DiscoveryOptions{})
+
+	s := found["xmp.png"]
+	if s == nil {
+		t.Fatal("expected the PNG screenshot to be found")
+	}
+
+	if expected := time.Date(2021, 6, 1, 7, 30, 0, 0, time.UTC); !s.Captured.Equal(expected) {
+		t.Errorf("expected the XMP creation time %s: %s", expected, s.Captured)
+	}
+
+	if s.Device != "Pixel 5" || s.Location != nil {
+		t.Errorf("expected the device model without a location: %q %+v", s.Device, s.Location)
+	}
+
+	plain := found["plain.png"]
+	if plain == nil {
+		t.Fatal("expected the PNG screenshot without metadata to be found")
+	}
+
+	fi, err := os.Stat(plain.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !plain.Captured.Equal(fi.ModTime()) {
+		t.Errorf("expected the modification time %s: %s", fi.ModTime(), plain.Captured)
+	}
+}
+
+// newScreenshotTree copies the screenshot without metadata into a
+// tree of directories, with a symbolic link to a directory outside
+// of the tree, and returns the root of the tree
+func newScreenshotTree(t *testing.T) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "screenshots", "plain.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{
+		"root/a.png",
+		"root/notes.txt",
+		"root/2021/b.png",
+		"root/2021/03/c.png",
+		"root/Thumbnails/d.png",
+		"linked/e.png",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink(filepath.Join(dir, "linked"), filepath.Join(dir, "root", "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestFindInPathFilters(t *testing.T) {
+	dir := newScreenshotTree(t)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+
+	for _, c := range []struct {
+		name     string
+		opts     models.DiscoveryOptions
+		expected []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"a.png", "2021/b.png", "2021/03/c.png", "Thumbnails/d.png"},
+		},
+		{
+			name:     "max depth",
+			opts:     models.DiscoveryOptions{MaxDepth: 2},
+			expected: []string{"a.png", "2021/b.png", "Thumbnails/d.png"},
+		},
+		{
+			name:     "exclude directory",
+			opts:     models.DiscoveryOptions{Exclude: []string{"Thumbnails"}},
+			expected: []string{"a.png", "2021/b.png", "2021/03/c.png"},
+		},
+		{
+			name:     "include",
+			opts:     models.DiscoveryOptions{Include: []string{"2021/*/*.png", "a.*"}},
+			expected: []string{"a.png", "2021/03/c.png"},
+		},
+		{
+			name:     "follow symlinks",
+			opts:     models.DiscoveryOptions{FollowSymlinks: true, MaxDepth: 2},
+			expected: []string{"a.png", "2021/b.png", "Thumbnails/d.png", "linked/e.png"},
+		},
+	} {
+		found := findScreenshots(t, root, c.opts)
+
+		if len(found) != len(c.expected) {
+			t.Errorf("%s: expected %v: %v", c.name, c.expected, found)
+		}
+
+		for _, e := range c.expected {
+			if _, ok := found[e]; !ok {
+				t.Errorf("%s: expected %s to be found", c.name, e)
+			}
+		}
+	}
+}
+
+func TestFindInPathCaptureRange(t *testing.T) {
+	found := findScreenshots(t, filepath.Join("testdata", "screenshots"), models.DiscoveryOptions{
+		Since: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC),
+	})
+
+	if _, ok := found["exif.jpg"]; len(found) != 1 || !ok {
+		t.Errorf("expected only the screenshot captured in March: %v", found)
+	}
+}
+
+func TestFindInPathHashesLargeImages(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "screenshots", "exif.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the image data following the metadata is larger than the part of
+	// the image read for its metadata
+	data = append(data, bytes.Repeat([]byte{0x5a}, 256*1024)...)
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "large.jpg"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := findScreenshots(t, dir, models.DiscoveryOptions{})["large.jpg"]
+	if s == nil {
+		t.Fatal("expected the large screenshot to be found")
+	}
+
+	if sum := sha256.Sum256(data); s.SHASum != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the shasum of the whole image: %s", s.SHASum)
+	}
+
+	if s.Device != "iPhone 12 Pro" {
+		t.Errorf("expected the device model from the metadata: %q", s.Device)
+	}
+}
+
+const largeSegmentsXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description xmlns:tiff="http://ns.adobe.com/tiff/1.0/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" ` +
+	`tiff:Model="Pixel 5" xmp:CreateDate="2021-06-01T07:30:00Z"/></rdf:RDF></x:xmpmeta>`
+
+// jpegSegment returns a JPEG segment with the marker and data
+func jpegSegment(marker byte, data []byte) []byte {
+	segment := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(data)+2))
+
+	return append(segment, data...)
+}
+
+// pngChunk returns a PNG chunk of the kind with the data
+func pngChunk(kind string, data []byte) []byte {
+	chunk := make([]byte, 4, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	chunk = append(append(chunk, kind...), data...)
+
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+
+	return append(chunk, crc...)
+}
+
+func TestFindInPathReadsMetadataAfterLargeSegments(t *testing.T) {
+	plain, err := ioutil.ReadFile(filepath.Join("testdata", "screenshots", "plain.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty little-endian TIFF structure padded to the largest
+	// segment, so the XMP following it is beyond the first 64 KiB
+	exif := append([]byte("Exif\x00\x00II*\x00\x08\x00\x00\x00\x00\x00"), make([]byte, 65533-16)...)
+
+	var jpeg []byte
+	for _, b := range [][]byte{
+		{0xff, 0xd8},
+		jpegSegment(0xe1, exif),
+		jpegSegment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), largeSegmentsXMP...)),
+		jpegSegment(0xda, make([]byte, 10)),
+		bytes.Repeat([]byte{0x5a}, 1024),
+		{0xff, 0xd9},
+	} {
+		jpeg = append(jpeg, b...)
+	}
+
+	// the chunks of plain.png follow the signature and 25 byte IHDR chunk
+	var png []byte
+	for _, b := range [][]byte{
+		plain[:33],
+		pngChunk("tEXt", append([]byte("Comment\x00"), bytes.Repeat([]byte{'a'}, 96*1024)...)),
+		pngChunk("iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), largeSegmentsXMP...)),
+		plain[33:],
+	} {
+		png = append(png, b...)
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{"large.jpg": jpeg, "large.png": png} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := findScreenshots(t, dir, models.DiscoveryOptions{})
+	for _, name := range []string{"large.jpg", "large.png"} {
+		s := found[name]
+		if s == nil {
+			t.Fatalf("expected %s to be found", name)
+		}
+
+		if expected := time.Date(2021, 6, 1, 7, 30, 0, 0, time.UTC); !s.Captured.Equal(expected) || s.Device != "Pixel 5" {
+			t.Errorf("expected the XMP following the large metadata of %s: %s %q", name, s.Captured, s.Device)
+		}
+	}
+}
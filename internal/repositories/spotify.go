@@ -1,10 +1,13 @@
 package repositories
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -17,6 +20,7 @@ import (
 	mrand "math/rand"
 
 	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
 	"github.com/pkg/browser"
 	"github.com/rs/zerolog/log"
 	"github.com/zmb3/spotify"
@@ -24,113 +28,288 @@ import (
 )
 
 const (
+	addTracksBatchSize    = 100
 	codeVerifierMaxLength = 128
 	codeVerifierMinLength = 43
+	maxCoverImageSize     = 256 * 1024
 	redirectURI           = "http://localhost:8080/callback"
+	spotifyAPIURL         = "https://api.spotify.com/v1"
+	spotifyProvider       = "spotify"
 	stateLength           = 36
 )
 
+// SpotifyOptions contains the settings for requests to the
+// Spotify Web API
+type SpotifyOptions struct {
+	// Token authorizing requests, which skips logging in with the
+	// browser when supplied
+	Token *oauth2.Token
+	// Transport used for requests to the Spotify Web API, such as one
+	// replaying recorded responses in tests, which defaults to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// spotifyTokenSource supplies the current token of the Spotify client
+type spotifyTokenSource struct {
+	client *spotify.Client
+}
+
+func (ts spotifyTokenSource) Token() (*oauth2.Token, error) {
+	return ts.client.Token()
+}
+
 type spotifyRepository struct {
 	auth          spotify.Authenticator
 	clientCH      chan *spotify.Client
 	client        *spotify.Client
 	codeChallenge string
 	codeVerifier  string
+	genres        map[string][]string
+	httpClient    *http.Client
+	options       SpotifyOptions
 	state         string
+	userID        string
 }
 
 // NewSpotifyRepository returns a new instance
-func NewSpotifyRepository() interfaces.ISpotifyRepository {
+func NewSpotifyRepository(options SpotifyOptions) interfaces.ISpotifyRepository {
 	return &spotifyRepository{
-		auth:     spotify.NewAuthenticator(redirectURI, spotify.ScopePlaylistModifyPrivate),
+		auth: spotify.NewAuthenticator(
+			redirectURI,
+			spotify.ScopeImageUpload,
+			spotify.ScopePlaylistModifyPrivate,
+			spotify.ScopePlaylistModifyPublic,
+			spotify.ScopePlaylistReadCollaborative,
+			spotify.ScopePlaylistReadPrivate),
 		clientCH: make(chan *spotify.Client),
+		genres:   map[string][]string{},
+		options:  options,
 	}
 }
 
-func (r *spotifyRepository) CreatePlaylist(user string, name string, tracks []spotify.SimpleTrack) error {
+// AddTracks adds the tracks to the playlist in batches of at most 100,
+// the limit Spotify allows per request, and returns the batches added
+// with their snapshot IDs, including those added before any error
+func (r *spotifyRepository) AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error) {
+	if len(tracks) == 0 {
+		return nil, nil
+	}
+
 	_, err := r.ensureClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if _, err := r.client.CreatePlaylistForUser(
-		user,
-		name,
-		"Playlist created by song-finder using image detection of screenshots",
-		false); err != nil {
-		return err
+	var batches []models.PlaylistBatch
+	for start := 0; start < len(tracks); start += addTracksBatchSize {
+		end := start + addTracksBatchSize
+		if end > len(tracks) {
+			end = len(tracks)
+		}
+
+		ids := make([]spotify.ID, 0, end-start)
+		batch := models.PlaylistBatch{}
+		for _, t := range tracks[start:end] {
+			ids = append(ids, spotify.ID(t.ID))
+			batch.TrackIDs = append(batch.TrackIDs, t.ID)
+		}
+
+		snapshotID, err := r.client.AddTracksToPlaylist(spotify.ID(playlist.ID), ids...)
+		if err != nil {
+			return batches, err
+		}
+
+		log.Debug().
+			Str("playlist", playlist.Name).
+			Str("snapshot", snapshotID).
+			Int("tracks", len(ids)).
+			Msg("added tracks to playlist")
+
+		batch.Added = time.Now()
+		batch.SnapshotID = snapshotID
+		batches = append(batches, batch)
 	}
 
-	return nil
+	return batches, nil
 }
 
-func (r *spotifyRepository) FindPlaylist(user string, name string) (spotify.SimplePlaylist, error) {
-	plp, err := r.client.GetPlaylistsForUser(user)
+// CreatePlaylist creates the playlist for the authenticated user,
+// marking it collaborative and uploading the JPEG cover image when
+// supplied in the options
+func (r *spotifyRepository) CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	if opts.Collaborative && opts.Public {
+		return models.Playlist{}, errors.New("spotify: collaborative playlists can not be public")
+	}
+
+	if base64.StdEncoding.EncodedLen(len(opts.Cover)) > maxCoverImageSize {
+		return models.Playlist{}, fmt.Errorf("spotify: cover image exceeds %d bytes when encoded", maxCoverImageSize)
+	}
+
+	_, err := r.ensureClient()
 	if err != nil {
-		return spotify.SimplePlaylist{}, err
+		return models.Playlist{}, err
 	}
 
-	if plp.Total == 0 {
-		return spotify.SimplePlaylist{}, nil
+	pl, err := r.client.CreatePlaylistForUser(
+		r.userID,
+		name,
+		playlistDescription(opts),
+		opts.Public)
+	if err != nil {
+		return models.Playlist{}, err
 	}
 
-	found := false
-	var rpl spotify.SimplePlaylist
+	if opts.Collaborative {
+		if err := r.setCollaborative(pl.ID); err != nil {
+			return toFullPlaylist(*pl), err
+		}
+
+		pl.Collaborative = true
+	}
+
+	if len(opts.Cover) > 0 {
+		if err := r.client.SetPlaylistImage(pl.ID, bytes.NewReader(opts.Cover)); err != nil {
+			log.Warn().Err(err).Str("playlist", name).Msg("unable to upload playlist cover image")
+		}
+	}
+
+	return toFullPlaylist(*pl), nil
+}
+
+func (r *spotifyRepository) FindPlaylist(name string) (*models.Playlist, error) {
+	_, err := r.ensureClient()
+	if err != nil {
+		return nil, err
+	}
+
+	plp, err := r.client.GetPlaylistsForUser(r.userID)
+	if err != nil {
+		return nil, err
+	}
 
 	for {
 		for _, pl := range plp.Playlists {
-			if pl.Name == name {
-				found = true
-				rpl = pl
+			// skip playlists followed by the user that they can not modify
+			if pl.Name == name && (pl.Owner.ID == r.userID || pl.Collaborative) {
+				found := toPlaylist(pl)
+				return &found, nil
 			}
 		}
 
-		if found {
-			break
-		}
-
 		err := r.client.NextPage(plp)
 		if err != nil {
 			if err == spotify.ErrNoMorePages {
-				break
+				return nil, nil
 			}
 
-			return spotify.SimplePlaylist{}, err
+			return nil, err
 		}
 	}
-
-	return rpl, nil
 }
 
-func (r *spotifyRepository) Search(searchTerm string) (spotify.SimpleTrack, error) {
-	if len(searchTerm) == 0 {
-		return spotify.SimpleTrack{}, nil
+// GetGenres retrieves the genres of the primary artist of the track,
+// caching the genres of each artist retrieved
+func (r *spotifyRepository) GetGenres(track models.Track) ([]string, error) {
+	_, err := r.ensureClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ft, err := r.client.GetTrack(spotify.ID(track.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ft.Artists) == 0 {
+		return nil, nil
+	}
+
+	id := string(ft.Artists[0].ID)
+	if genres, ok := r.genres[id]; ok {
+		return genres, nil
+	}
+
+	fa, err := r.client.GetArtist(ft.Artists[0].ID)
+	if err != nil {
+		return nil, err
 	}
 
+	r.genres[id] = fa.Genres
+
+	return fa.Genres, nil
+}
+
+// GetPlaylistTracks retrieves each track in the playlist, skipping
+// local files that are not available from Spotify
+func (r *spotifyRepository) GetPlaylistTracks(playlist models.Playlist) ([]models.Track, error) {
 	_, err := r.ensureClient()
 	if err != nil {
-		return spotify.SimpleTrack{}, err
+		return nil, err
 	}
 
-	log.Debug().Str("song", searchTerm).Msg("searching for song")
+	ptp, err := r.client.GetPlaylistTracks(spotify.ID(playlist.ID))
+	if err != nil {
+		return nil, err
+	}
 
-	results, err := r.client.Search(searchTerm, spotify.SearchTypeTrack)
+	tracks := make([]models.Track, 0, ptp.Total)
+	for {
+		for _, pt := range ptp.Tracks {
+			if pt.IsLocal || pt.Track.ID == "" {
+				continue
+			}
+
+			tracks = append(tracks, toTrack(pt.Track))
+		}
+
+		err := r.client.NextPage(ptp)
+		if err != nil {
+			if err == spotify.ErrNoMorePages {
+				return tracks, nil
+			}
+
+			return nil, err
+		}
+	}
+}
+
+// GetTrack retrieves the track by its Spotify ID and returns nil
+// when the track does not exist
+func (r *spotifyRepository) GetTrack(id string) (*models.Track, error) {
+	_, err := r.ensureClient()
 	if err != nil {
-		log.Debug().Str("song", searchTerm).Stack().Err(err).Msg("error searching for song")
-		return spotify.SimpleTrack{}, err
+		return nil, err
 	}
 
-	if results.Tracks == nil || results.Tracks.Total == 0 {
-		log.Debug().Str("song", searchTerm).Msg("no matches found for song")
-		return spotify.SimpleTrack{}, nil
+	ft, err := r.client.GetTrack(spotify.ID(id))
+	if err != nil {
+		if se, ok := err.(spotify.Error); ok && se.Status == http.StatusNotFound {
+			return nil, nil
+		}
+
+		return nil, err
 	}
 
-	log.Debug().
-		Str("song", searchTerm).
-		Int("matches", results.Tracks.Total).
-		Msg("match(es) found while searching for song")
+	track := toTrack(*ft)
 
-	return results.Tracks.Tracks[0].SimpleTrack, nil
+	return &track, nil
+}
+
+func (r *spotifyRepository) Search(searchTerm string, limit int) ([]models.Track, error) {
+	if len(searchTerm) == 0 {
+		return nil, nil
+	}
+
+	return r.searchTracks(searchTerm, limit)
+}
+
+func (r *spotifyRepository) SearchISRC(isrc string, limit int) ([]models.Track, error) {
+	if len(isrc) == 0 {
+		return nil, nil
+	}
+
+	return r.searchTracks("isrc:"+isrc, limit)
 }
 
 func (r *spotifyRepository) completeAuth(w http.ResponseWriter, res *http.Request) {
@@ -153,7 +332,7 @@ func (r *spotifyRepository) completeAuth(w http.ResponseWriter, res *http.Reques
 			Msg("state mismatch")
 	}
 
-	cl := r.auth.NewClient(tok)
+	cl := r.authorize(tok)
 	fmt.Fprint(w, `<!DOCTYPE html><html lang="en"><head><title>Song Finder: Spotify Auth</title></head><body>
 	<p>
 		<label>Login process completed</label>
@@ -161,7 +340,30 @@ func (r *spotifyRepository) completeAuth(w http.ResponseWriter, res *http.Reques
 		<div><img src="/assets/img" /></div>
 	</p>
 </body></html>`)
-	r.clientCH <- &cl
+	r.clientCH <- cl
+}
+
+// authorize returns a client for requests authorized with the token,
+// sending requests with the transport from the options when supplied
+func (r *spotifyRepository) authorize(tok *oauth2.Token) *spotify.Client {
+	if r.options.Transport == nil {
+		cl := r.auth.NewClient(tok)
+
+		// requests the client doesn't support use its token, which it
+		// refreshes once expired
+		r.httpClient = oauth2.NewClient(context.Background(), spotifyTokenSource{&cl})
+
+		return &cl
+	}
+
+	ctx := context.WithValue(
+		context.Background(),
+		oauth2.HTTPClient,
+		&http.Client{Transport: r.options.Transport})
+	r.httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(tok))
+	cl := spotify.NewClient(r.httpClient)
+
+	return &cl
 }
 
 func (r *spotifyRepository) ensureClient() (*spotify.Client, error) {
@@ -169,6 +371,11 @@ func (r *spotifyRepository) ensureClient() (*spotify.Client, error) {
 		return r.client, nil
 	}
 
+	if r.options.Token != nil {
+		r.client = r.authorize(r.options.Token)
+		return r.client, r.setUserID()
+	}
+
 	// ensurer state, codeChallenge and codeVerifier are set
 	log.Debug().Msg("setting OAuth params")
 	if err := r.setOauthParams(); err != nil {
@@ -202,13 +409,87 @@ func (r *spotifyRepository) ensureClient() (*spotify.Client, error) {
 	// wait for goroutine in startServer to complete
 	swg.Wait()
 
-	user, err := cl.CurrentUser()
+	return r.client, r.setUserID()
+}
+
+func (r *spotifyRepository) searchTracks(query string, limit int) ([]models.Track, error) {
+	_, err := r.ensureClient()
 	if err != nil {
-		return r.client, err
+		return nil, err
+	}
+
+	log.Debug().Str("song", query).Msg("searching for song")
+
+	results, err := r.client.SearchOpt(
+		query,
+		spotify.SearchTypeTrack,
+		&spotify.Options{Limit: &limit})
+	if err != nil {
+		log.Debug().Str("song", query).Stack().Err(err).Msg("error searching for song")
+		return nil, err
+	}
+
+	if results.Tracks == nil || results.Tracks.Total == 0 {
+		log.Debug().Str("song", query).Msg("no matches found for song")
+		return nil, nil
+	}
+
+	log.Debug().
+		Str("song", query).
+		Int("matches", results.Tracks.Total).
+		Msg("match(es) found while searching for song")
+
+	tracks := make([]models.Track, 0, len(results.Tracks.Tracks))
+	for _, t := range results.Tracks.Tracks {
+		tracks = append(tracks, toTrack(t))
+	}
+
+	return tracks, nil
+}
+
+// setCollaborative marks the playlist as collaborative, which the
+// Spotify client library does not support when changing a playlist
+func (r *spotifyRepository) setCollaborative(id spotify.ID) error {
+	body, err := json.Marshal(map[string]bool{
+		"collaborative": true,
+		"public":        false,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPut,
+		fmt.Sprintf("%s/playlists/%s", spotifyAPIURL, id),
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("spotify: unable to make playlist collaborative: %s", res.Status)
 	}
 
+	return nil
+}
+
+func (r *spotifyRepository) setUserID() error {
+	user, err := r.client.CurrentUser()
+	if err != nil {
+		return err
+	}
+
+	r.userID = user.ID
 	log.Debug().Str("User.ID", user.ID).Msg("user authenticated")
-	return r.client, nil
+	return nil
 }
 
 func (r *spotifyRepository) setOauthParams() error {
@@ -270,6 +551,48 @@ func (r *spotifyRepository) startServer(wg *sync.WaitGroup) *http.Server {
 	return srv
 }
 
+func toFullPlaylist(pl spotify.FullPlaylist) models.Playlist {
+	playlist := toPlaylist(pl.SimplePlaylist)
+	playlist.Description = pl.Description
+	playlist.TrackCount = pl.Tracks.Total
+
+	return playlist
+}
+
+func toPlaylist(pl spotify.SimplePlaylist) models.Playlist {
+	return models.Playlist{
+		Collaborative: pl.Collaborative,
+		ID:            string(pl.ID),
+		Name:          pl.Name,
+		Owner:         pl.Owner.ID,
+		Provider:      spotifyProvider,
+		Public:        pl.IsPublic,
+		SnapshotID:    pl.SnapshotID,
+		TrackCount:    int(pl.Tracks.Total),
+		URI:           string(pl.URI),
+		URL:           pl.ExternalURLs["spotify"],
+	}
+}
+
+func toTrack(t spotify.FullTrack) models.Track {
+	track := models.Track{
+		Album:      t.Album.Name,
+		DurationMS: t.Duration,
+		ID:         string(t.ID),
+		ISRC:       t.ExternalIDs["isrc"],
+		Name:       t.Name,
+		Provider:   spotifyProvider,
+		URI:        string(t.URI),
+		URL:        t.ExternalURLs["spotify"],
+	}
+
+	for _, a := range t.Artists {
+		track.Artists = append(track.Artists, a.Name)
+	}
+
+	return track
+}
+
 func encode(msg []byte) string {
 	encoded := base64.StdEncoding.EncodeToString(msg)
 	encoded = strings.Replace(encoded, "+", "-", -1)
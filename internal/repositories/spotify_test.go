@@ -1,14 +1,146 @@
 package repositories_test
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
 	"github.com/brozeph/song-finder/internal/repositories"
+	"golang.org/x/oauth2"
 )
 
+// newSpotifyRepository returns a repository replaying the cassette, or
+// logging in with the browser to record it from the Spotify Web API
+func newSpotifyRepository(t *testing.T, name string) interfaces.ISpotifyRepository {
+	c := newCassette(t, name)
+	if c.recording() {
+		return repositories.NewSpotifyRepository(repositories.SpotifyOptions{Transport: c})
+	}
+
+	return repositories.NewSpotifyRepository(repositories.SpotifyOptions{
+		Token:     &oauth2.Token{AccessToken: "recorded-access-token", TokenType: "Bearer"},
+		Transport: c,
+	})
+}
+
 func TestSearch(t *testing.T) {
-	spotifyRepository := repositories.NewSpotifyRepository()
-	if _, err := spotifyRepository.Search("Beck Mixed Business"); err != nil {
-		t.Error(err)
+	spotifyRepository := newSpotifyRepository(t, "search")
+
+	tracks, err := spotifyRepository.Search("Beck Mixed Business", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := models.Track{
+		Album:      "Midnite Vultures",
+		Artists:    []string{"Beck"},
+		DurationMS: 248426,
+		ID:         "0BGlVOlDvHNgqSC7A5Ie9R",
+		ISRC:       "USIR19915123",
+		Name:       "Mixed Bizness",
+		Provider:   "spotify",
+		URI:        "spotify:track:0BGlVOlDvHNgqSC7A5Ie9R",
+		URL:        "https://open.spotify.com/track/0BGlVOlDvHNgqSC7A5Ie9R",
+	}
+
+	if len(tracks) != 1 || !reflect.DeepEqual(tracks[0], expected) {
+		t.Errorf("expected %+v: %+v", expected, tracks)
+	}
+
+	if tracks, err = spotifyRepository.SearchISRC("USIR19915123", 1); err != nil || len(tracks) != 1 || tracks[0].ID != expected.ID {
+		t.Errorf("expected the track to be found by ISRC: %+v %v", tracks, err)
+	}
+
+	if tracks, err = spotifyRepository.Search("Beck Mixed Bizness Remix", 1); err != nil || len(tracks) != 0 {
+		t.Errorf("expected no tracks: %+v %v", tracks, err)
+	}
+}
+
+func TestSpotifyGetTrack(t *testing.T) {
+	spotifyRepository := newSpotifyRepository(t, "get-track")
+
+	track, err := spotifyRepository.GetTrack("0BGlVOlDvHNgqSC7A5Ie9R")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track == nil || track.Name != "Mixed Bizness" || track.ISRC != "USIR19915123" {
+		t.Errorf("expected the track to be retrieved: %+v", track)
+	}
+
+	if track, err = spotifyRepository.GetTrack("0000000000000000000000"); err != nil || track != nil {
+		t.Errorf("expected no track or error for a track that does not exist: %+v %v", track, err)
+	}
+}
+
+func TestSpotifyGetGenres(t *testing.T) {
+	spotifyRepository := newSpotifyRepository(t, "get-genres")
+
+	for i := 0; i < 2; i++ {
+		genres, err := spotifyRepository.GetGenres(models.Track{ID: "0BGlVOlDvHNgqSC7A5Ie9R"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the genres of the artist are retrieved once
+		if !reflect.DeepEqual(genres, []string{"alternative rock", "anti-folk", "permanent wave"}) {
+			t.Errorf("unexpected genres: %v", genres)
+		}
+	}
+}
+
+func TestSpotifyFindPlaylist(t *testing.T) {
+	spotifyRepository := newSpotifyRepository(t, "find-playlist")
+
+	pl, err := spotifyRepository.FindPlaylist("Found Songs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the playlist followed by the user on the first page is skipped
+	if pl == nil || pl.ID != "3cEYpjA9oz9GiPac4AsH4n" || pl.TrackCount != 2 {
+		t.Errorf("expected the playlist owned by the user on the second page: %+v", pl)
+	}
+
+	tracks, err := spotifyRepository.GetPlaylistTracks(*pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) != 1 || tracks[0].ID != "0BGlVOlDvHNgqSC7A5Ie9R" {
+		t.Errorf("expected the local file in the playlist to be skipped: %+v", tracks)
+	}
+}
+
+func TestSpotifyCreatePlaylistAndAddTracks(t *testing.T) {
+	spotifyRepository := newSpotifyRepository(t, "create-playlist")
+
+	pl, err := spotifyRepository.CreatePlaylist("New Songs", models.PlaylistOptions{
+		Collaborative: true,
+		Cover:         []byte("cover"),
+		Description:   "Songs found in 101 screenshots",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pl.ID != "7d2D2S200NyUE5KYs80PwO" || !pl.Collaborative || pl.Public {
+		t.Errorf("expected a collaborative playlist to be created: %+v", pl)
+	}
+
+	tracks := make([]models.Track, 0, 101)
+	for i := 0; i < 101; i++ {
+		tracks = append(tracks, models.Track{ID: fmt.Sprintf("%022d", i)})
+	}
+
+	batches, err := spotifyRepository.AddTracks(pl, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 2 || len(batches[0].TrackIDs) != 100 || batches[1].SnapshotID != "MyxkNzU2YzBjNGM3" {
+		t.Errorf("expected the tracks to be added in two batches: %+v", batches)
 	}
 }
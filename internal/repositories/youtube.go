@@ -0,0 +1,580 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/pkg/browser"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	youTubeAuthorizeTimeout = 5 * time.Minute
+	youTubeBaseURL          = "https://www.googleapis.com/youtube/v3"
+	youTubeMinimumResults   = 10
+	youTubeMusicCategory    = "10"
+	youTubeProvider         = "youtube"
+	youTubeScope            = "https://www.googleapis.com/auth/youtube"
+	youTubeTopicSuffix      = " - Topic"
+)
+
+// YouTubeOptions contains the OAuth client credentials and settings
+// for the YouTube Data API
+type YouTubeOptions struct {
+	// AuthorizeTimeout is how long to wait for the consent page to
+	// redirect back when authorizing, which defaults to 5 minutes
+	AuthorizeTimeout time.Duration
+	// BaseURL of the YouTube Data API, which defaults to
+	// https://www.googleapis.com/youtube/v3
+	BaseURL string
+	// ClientID of the OAuth client for an installed application
+	ClientID string
+	// ClientSecret of the OAuth client for an installed application
+	ClientSecret string
+	// Endpoint of the OAuth provider, which defaults to Google
+	Endpoint oauth2.Endpoint
+	// OpenURL opens the consent page when authorizing, which defaults
+	// to opening it in the browser
+	OpenURL func(url string) error
+	// TokenPath where the OAuth token is persisted between runs
+	TokenPath string
+}
+
+type youTubeRepository struct {
+	client  *http.Client
+	config  *oauth2.Config
+	lock    sync.Mutex
+	options YouTubeOptions
+}
+
+// youTubeCallback is the code, or the error, the consent page
+// redirected back with
+type youTubeCallback struct {
+	code string
+	err  error
+}
+
+type youTubeError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type youTubeResource struct {
+	ID      json.RawMessage `json:"id"`
+	Snippet struct {
+		ChannelTitle string `json:"channelTitle"`
+		Title        string `json:"title"`
+	} `json:"snippet"`
+}
+
+type youTubeListResponse struct {
+	Items         []youTubeResource `json:"items"`
+	NextPageToken string            `json:"nextPageToken"`
+}
+
+// tokenFile persists each token retrieved from the token source
+// so subsequent runs do not need to authorize again
+type tokenFile struct {
+	lock   sync.Mutex
+	last   *oauth2.Token
+	path   string
+	source oauth2.TokenSource
+}
+
+// NewYouTubeRepository returns a new instance
+func NewYouTubeRepository(options YouTubeOptions) interfaces.IMusicProvider {
+	if options.AuthorizeTimeout == 0 {
+		options.AuthorizeTimeout = youTubeAuthorizeTimeout
+	}
+
+	if options.BaseURL == "" {
+		options.BaseURL = youTubeBaseURL
+	}
+
+	if options.Endpoint.TokenURL == "" {
+		options.Endpoint = google.Endpoint
+	}
+
+	if options.OpenURL == nil {
+		options.OpenURL = browser.OpenURL
+	}
+
+	return &youTubeRepository{
+		config: &oauth2.Config{
+			ClientID:     options.ClientID,
+			ClientSecret: options.ClientSecret,
+			Endpoint:     options.Endpoint,
+			Scopes:       []string{youTubeScope},
+		},
+		options: options,
+	}
+}
+
+// AddTracks inserts each track into the playlist, YouTube accepts a
+// single item per request so each track is returned as its own batch
+// with the ID of the playlist item created
+func (r *youTubeRepository) AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error) {
+	var batches []models.PlaylistBatch
+	for _, t := range tracks {
+		body := map[string]interface{}{
+			"snippet": map[string]interface{}{
+				"playlistId": playlist.ID,
+				"resourceId": map[string]string{
+					"kind":    "youtube#video",
+					"videoId": t.ID,
+				},
+			},
+		}
+
+		res := youTubeResource{}
+		if err := r.request(http.MethodPost, "/playlistItems?part=snippet", body, &res); err != nil {
+			return batches, err
+		}
+
+		batches = append(batches, models.PlaylistBatch{
+			Added:      time.Now(),
+			SnapshotID: resourceID(res),
+			TrackIDs:   []string{t.ID},
+		})
+	}
+
+	return batches, nil
+}
+
+// CreatePlaylist creates a public or private playlist with the name
+// and description, YouTube does not support collaborative playlists
+// or custom thumbnails through the API
+func (r *youTubeRepository) CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	if opts.Collaborative || len(opts.Cover) > 0 {
+		log.Warn().
+			Str("playlist", name).
+			Msg("YouTube playlists do not support collaboration or cover images")
+	}
+
+	privacyStatus := "private"
+	if opts.Public {
+		privacyStatus = "public"
+	}
+
+	body := map[string]interface{}{
+		"snippet": map[string]string{
+			"description": playlistDescription(opts),
+			"title":       name,
+		},
+		"status": map[string]string{
+			"privacyStatus": privacyStatus,
+		},
+	}
+
+	res := youTubeResource{}
+	if err := r.request(http.MethodPost, "/playlists?part=snippet,status", body, &res); err != nil {
+		return models.Playlist{}, err
+	}
+
+	return toYouTubePlaylist(res), nil
+}
+
+func (r *youTubeRepository) FindPlaylist(name string) (*models.Playlist, error) {
+	q := url.Values{}
+	q.Set("maxResults", "50")
+	q.Set("mine", "true")
+	q.Set("part", "snippet")
+
+	for {
+		res := youTubeListResponse{}
+		if err := r.request(http.MethodGet, "/playlists?"+q.Encode(), nil, &res); err != nil {
+			return nil, err
+		}
+
+		for _, pl := range res.Items {
+			if found := toYouTubePlaylist(pl); found.Name == name {
+				return &found, nil
+			}
+		}
+
+		if res.NextPageToken == "" {
+			return nil, nil
+		}
+
+		q.Set("pageToken", res.NextPageToken)
+	}
+}
+
+// GetTrack retrieves the music video by its YouTube ID and returns
+// nil when the video does not exist
+func (r *youTubeRepository) GetTrack(id string) (*models.Track, error) {
+	q := url.Values{}
+	q.Set("id", id)
+	q.Set("part", "snippet")
+
+	res := youTubeListResponse{}
+	if err := r.request(http.MethodGet, "/videos?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Items) == 0 {
+		return nil, nil
+	}
+
+	track := toYouTubeTrack(res.Items[0])
+
+	return &track, nil
+}
+
+// Search finds music videos for the search term, preferring the
+// official audio uploaded to the artist's "Topic" channel
+func (r *youTubeRepository) Search(searchTerm string, limit int) ([]models.Track, error) {
+	if len(searchTerm) == 0 {
+		return nil, nil
+	}
+
+	log.Debug().Str("song", searchTerm).Msg("searching YouTube for song")
+
+	// request enough results for a Topic channel upload to be found
+	max := limit
+	if max < youTubeMinimumResults {
+		max = youTubeMinimumResults
+	}
+
+	q := url.Values{}
+	q.Set("maxResults", strconv.Itoa(max))
+	q.Set("part", "snippet")
+	q.Set("q", searchTerm)
+	q.Set("type", "video")
+	q.Set("videoCategoryId", youTubeMusicCategory)
+
+	res := youTubeListResponse{}
+	if err := r.request(http.MethodGet, "/search?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Items) == 0 {
+		log.Debug().Str("song", searchTerm).Msg("no matches found for song")
+		return nil, nil
+	}
+
+	// Topic channels first, otherwise keep the order of relevance
+	sort.SliceStable(res.Items, func(i, j int) bool {
+		return isTopicChannel(res.Items[i]) && !isTopicChannel(res.Items[j])
+	})
+
+	if len(res.Items) > limit {
+		res.Items = res.Items[:limit]
+	}
+
+	tracks := make([]models.Track, 0, len(res.Items))
+	for _, v := range res.Items {
+		tracks = append(tracks, toYouTubeTrack(v))
+	}
+
+	return tracks, nil
+}
+
+// SearchISRC returns no tracks as the YouTube Data API is unable
+// to search by ISRC, so the search term is used instead
+func (r *youTubeRepository) SearchISRC(isrc string, limit int) ([]models.Track, error) {
+	return nil, nil
+}
+
+// authorize runs the OAuth flow for an installed application by
+// opening the consent page in the browser and receiving the code
+// on a loopback redirect, giving up once the authorize timeout passes
+func (r *youTubeRepository) authorize(ctx context.Context) (*oauth2.Token, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	cv, err := randomBytes(codeVerifierMinLength)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := randomBytes(stateLength)
+	if err != nil {
+		return nil, err
+	}
+
+	wait, cancel := context.WithTimeout(ctx, r.options.AuthorizeTimeout)
+	defer cancel()
+
+	var (
+		callbackCH   = make(chan youTubeCallback, 1)
+		codeVerifier = encode(cv)
+		h            = sha256.Sum256([]byte(codeVerifier))
+		state        = encode(st)
+	)
+
+	r.config.RedirectURL = fmt.Sprintf("http://%s/callback", ln.Addr().String())
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/callback" {
+			http.NotFound(w, req)
+			return
+		}
+
+		cb := youTubeCallback{code: req.FormValue("code")}
+		switch {
+		case req.FormValue("state") != state:
+			cb.err = errors.New("youtube: state mismatch in authorization callback")
+		case req.FormValue("error") != "":
+			cb.err = fmt.Errorf("youtube: authorization failed: %s", req.FormValue("error"))
+		case cb.code == "":
+			cb.err = errors.New("youtube: no code in authorization callback")
+		}
+
+		if cb.err != nil {
+			http.Error(w, cb.err.Error(), http.StatusForbidden)
+		} else {
+			fmt.Fprint(w, `<!DOCTYPE html><html lang="en"><head><title>Song Finder: YouTube Auth</title></head><body>
+	<p>
+		<label>Login process completed</label>
+		<div>You may close this window now.</div>
+	</p>
+</body></html>`)
+		}
+
+		// only the first callback is waited for
+		select {
+		case callbackCH <- cb:
+		default:
+		}
+	})}
+
+	go func() {
+		if err := srv.Serve(ln); err != http.ErrServerClosed {
+			log.Error().Stack().Err(err).Msg("unable to serve YouTube auth callback")
+		}
+	}()
+
+	defer srv.Shutdown(ctx)
+
+	url := r.config.AuthCodeURL(
+		state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		oauth2.SetAuthURLParam("code_challenge", encode(h[:])))
+
+	log.Debug().Str("URL", url).Msg("YouTube login URL created")
+	if err := r.options.OpenURL(url); err != nil {
+		log.Warn().Err(err).Str("URL", url).Msg("unable to open the YouTube login URL, open it in a browser to continue")
+	}
+
+	var cb youTubeCallback
+	select {
+	case cb = <-callbackCH:
+	case <-wait.Done():
+		return nil, fmt.Errorf("youtube: no authorization callback received: %v", wait.Err())
+	}
+
+	if cb.err != nil {
+		return nil, cb.err
+	}
+
+	return r.config.Exchange(
+		ctx,
+		cb.code,
+		oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+}
+
+func (r *youTubeRepository) ensureClient() (*http.Client, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.client != nil {
+		return r.client, nil
+	}
+
+	ctx := context.Background()
+	tf := &tokenFile{path: r.options.TokenPath}
+
+	tok, err := tf.load()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if tok, err = r.authorize(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	tf.source = r.config.TokenSource(ctx, tok)
+	r.client = oauth2.NewClient(ctx, tf)
+
+	return r.client, nil
+}
+
+// request sends the body, when supplied, as JSON to the path of the YouTube
+// Data API and decodes the JSON response into v, when supplied
+func (r *youTubeRepository) request(method string, path string, body interface{}, v interface{}) error {
+	client, err := r.ensureClient()
+	if err != nil {
+		return err
+	}
+
+	var rdr io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		rdr = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, r.options.BaseURL+path, rdr)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		yte := youTubeError{}
+		if err := json.NewDecoder(res.Body).Decode(&yte); err == nil && yte.Error.Message != "" {
+			return fmt.Errorf("youtube: %s %s: %s", method, path, yte.Error.Message)
+		}
+
+		return fmt.Errorf("youtube: %s %s: %s", method, path, res.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (tf *tokenFile) load() (*oauth2.Token, error) {
+	if tf.path == "" {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(tf.path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	tok := &oauth2.Token{}
+	if err := json.NewDecoder(f).Decode(tok); err != nil {
+		return nil, err
+	}
+
+	tf.last = tok
+
+	return tok, nil
+}
+
+// Token returns a token from the source, persisting it
+// whenever it has been refreshed
+func (tf *tokenFile) Token() (*oauth2.Token, error) {
+	tf.lock.Lock()
+	defer tf.lock.Unlock()
+
+	tok, err := tf.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tf.path == "" || (tf.last != nil && tf.last.AccessToken == tok.AccessToken) {
+		return tok, nil
+	}
+
+	b, err := json.MarshalIndent(tok, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(tf.path, b, 0600); err != nil {
+		log.Error().Stack().Err(err).Msg("unable to persist the YouTube token")
+	}
+
+	tf.last = tok
+
+	return tok, nil
+}
+
+func isTopicChannel(v youTubeResource) bool {
+	return strings.HasSuffix(v.Snippet.ChannelTitle, youTubeTopicSuffix)
+}
+
+// resourceID returns the ID of a search result ({"videoId": "..."})
+// or a resource ("...")
+func resourceID(v youTubeResource) string {
+	var id string
+	if err := json.Unmarshal(v.ID, &id); err == nil {
+		return id
+	}
+
+	sr := struct {
+		PlaylistID string `json:"playlistId"`
+		VideoID    string `json:"videoId"`
+	}{}
+
+	if err := json.Unmarshal(v.ID, &sr); err != nil {
+		log.Debug().Err(err).Msg("unable to read YouTube resource ID")
+	}
+
+	if sr.VideoID != "" {
+		return sr.VideoID
+	}
+
+	return sr.PlaylistID
+}
+
+func toYouTubePlaylist(pl youTubeResource) models.Playlist {
+	id := resourceID(pl)
+
+	return models.Playlist{
+		ID:       id,
+		Name:     html.UnescapeString(pl.Snippet.Title),
+		Provider: youTubeProvider,
+		URI:      fmt.Sprintf("%s:playlist:%s", youTubeProvider, id),
+		URL:      "https://music.youtube.com/playlist?list=" + id,
+	}
+}
+
+func toYouTubeTrack(v youTubeResource) models.Track {
+	id := resourceID(v)
+
+	return models.Track{
+		Artists:  []string{html.UnescapeString(strings.TrimSuffix(v.Snippet.ChannelTitle, youTubeTopicSuffix))},
+		ID:       id,
+		Name:     html.UnescapeString(v.Snippet.Title),
+		Provider: youTubeProvider,
+		URI:      fmt.Sprintf("%s:video:%s", youTubeProvider, id),
+		URL:      "https://music.youtube.com/watch?v=" + id,
+	}
+}
@@ -0,0 +1,317 @@
+package repositories_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/repositories"
+	"golang.org/x/oauth2"
+)
+
+const (
+	youTubeAccessToken  = "refreshed-access-token"
+	youTubeAuthCode     = "auth-code"
+	youTubeRefreshToken = "refresh-token"
+)
+
+// newYouTubeFake returns an httptest fake of the YouTube Data API and
+// Google token endpoint serving the recorded fixtures for the routes
+func newYouTubeFake(t *testing.T, routes map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			refreshed := r.FormValue("grant_type") == "refresh_token" && r.FormValue("refresh_token") == youTubeRefreshToken
+			exchanged := r.FormValue("grant_type") == "authorization_code" && r.FormValue("code") == youTubeAuthCode && r.FormValue("code_verifier") != ""
+			if !refreshed && !exchanged {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  youTubeAccessToken,
+				"expires_in":    3600,
+				"refresh_token": youTubeRefreshToken,
+				"token_type":    "Bearer",
+			})
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+youTubeAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fixture, ok := routes[r.Method+" "+r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+
+		if strings.HasPrefix(fixture, "forbidden") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+
+		serveFixture(t, w, "youtube", fixture)
+	}))
+}
+
+// newYouTubeRepository returns a repository with a persisted, expired
+// token that must be refreshed with the fake token endpoint
+func newYouTubeRepository(t *testing.T, routes map[string]string) (interfaces.IMusicProvider, string, func()) {
+	srv := newYouTubeFake(t, routes)
+	tokenPath := filepath.Join(t.TempDir(), "youtube.token.json")
+
+	b, err := json.Marshal(&oauth2.Token{
+		AccessToken:  "expired-access-token",
+		Expiry:       time.Now().Add(-time.Hour),
+		RefreshToken: youTubeRefreshToken,
+		TokenType:    "Bearer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(tokenPath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ytr := repositories.NewYouTubeRepository(repositories.YouTubeOptions{
+		BaseURL:      srv.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  srv.URL + "/auth",
+			TokenURL: srv.URL + "/token",
+		},
+		TokenPath: tokenPath,
+	})
+
+	return ytr, tokenPath, srv.Close
+}
+
+func TestYouTubeSearchPrefersTopicChannel(t *testing.T) {
+	ytr, tokenPath, done := newYouTubeRepository(t, map[string]string{
+		"GET /search?maxResults=10&part=snippet&q=sg+lewis+chemicals&type=video&videoCategoryId=10": "search.json",
+	})
+	defer done()
+
+	tracks, err := ytr.Search("sg lewis chemicals", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracks) != 2 {
+		t.Fatalf("expected 2 tracks: %v", tracks)
+	}
+
+	if tracks[0].ID != "topicAudio1" || tracks[0].Artists[0] != "SG Lewis" || tracks[0].Name != "Chemicals" {
+		t.Errorf("expected the Topic channel upload first: %+v", tracks[0])
+	}
+
+	if tracks[1].ID != "mvLyric0001" || tracks[1].URL != "https://music.youtube.com/watch?v=mvLyric0001" {
+		t.Errorf("expected the most relevant remaining video second: %+v", tracks[1])
+	}
+
+	// the refreshed token is persisted for subsequent runs
+	tok := oauth2.Token{}
+	b, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(b, &tok); err != nil {
+		t.Fatal(err)
+	}
+
+	if tok.AccessToken != youTubeAccessToken || tok.RefreshToken != youTubeRefreshToken {
+		t.Errorf("expected the refreshed token to be persisted: %+v", tok)
+	}
+}
+
+// newAuthorizingYouTubeRepository returns a repository without a
+// persisted token that follows the consent page by requesting the
+// redirect with the query returned by callback
+func newAuthorizingYouTubeRepository(t *testing.T, callback func(state string) url.Values) (interfaces.IMusicProvider, func()) {
+	srv := newYouTubeFake(t, map[string]string{
+		"GET /videos?id=topicAudio1&part=snippet": "videos.json",
+	})
+
+	ytr := repositories.NewYouTubeRepository(repositories.YouTubeOptions{
+		AuthorizeTimeout: 100 * time.Millisecond,
+		BaseURL:          srv.URL,
+		ClientID:         "client-id",
+		ClientSecret:     "client-secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  srv.URL + "/auth",
+			TokenURL: srv.URL + "/token",
+		},
+		OpenURL: func(consent string) error {
+			u, err := url.Parse(consent)
+			if err != nil {
+				return err
+			}
+
+			q := callback(u.Query().Get("state"))
+			if q == nil {
+				return nil
+			}
+
+			go func() {
+				res, err := http.Get(u.Query().Get("redirect_uri") + "?" + q.Encode())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				res.Body.Close()
+			}()
+
+			return nil
+		},
+		TokenPath: filepath.Join(t.TempDir(), "youtube.token.json"),
+	})
+
+	return ytr, srv.Close
+}
+
+func TestYouTubeAuthorize(t *testing.T) {
+	tests := []struct {
+		name     string
+		callback func(state string) url.Values
+		expected string
+	}{
+		{
+			name: "code",
+			callback: func(state string) url.Values {
+				return url.Values{"code": {youTubeAuthCode}, "state": {state}}
+			},
+		},
+		{
+			name: "denied",
+			callback: func(state string) url.Values {
+				return url.Values{"error": {"access_denied"}, "state": {state}}
+			},
+			expected: "authorization failed: access_denied",
+		},
+		{
+			name: "state mismatch",
+			callback: func(state string) url.Values {
+				return url.Values{"code": {youTubeAuthCode}, "state": {"forged"}}
+			},
+			expected: "state mismatch",
+		},
+		{
+			name:     "no callback",
+			callback: func(state string) url.Values { return nil },
+			expected: "no authorization callback received",
+		},
+	}
+
+	for _, test := range tests {
+		ytr, done := newAuthorizingYouTubeRepository(t, test.callback)
+
+		track, err := ytr.GetTrack("topicAudio1")
+		done()
+
+		if test.expected == "" {
+			if err != nil || track == nil {
+				t.Errorf("expected the %s callback to authorize: %v %+v", test.name, err, track)
+			}
+
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected the %s callback to fail with %q: %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestYouTubeGetTrack(t *testing.T) {
+	ytr, _, done := newYouTubeRepository(t, map[string]string{
+		"GET /videos?id=topicAudio1&part=snippet": "videos.json",
+		"GET /videos?id=missingVid1&part=snippet": "videos-empty.json",
+	})
+	defer done()
+
+	track, err := ytr.GetTrack("topicAudio1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track == nil || track.ID != "topicAudio1" || track.Artists[0] != "SG Lewis" || track.URI != "youtube:video:topicAudio1" {
+		t.Errorf("expected video topicAudio1: %+v", track)
+	}
+
+	track, err = ytr.GetTrack("missingVid1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if track != nil {
+		t.Errorf("expected no video to be found: %+v", track)
+	}
+}
+
+func TestYouTubeFindPlaylist(t *testing.T) {
+	ytr, _, done := newYouTubeRepository(t, map[string]string{
+		"GET /playlists?maxResults=50&mine=true&part=snippet":                  "playlists.json",
+		"GET /playlists?maxResults=50&mine=true&pageToken=CAEQAA&part=snippet": "playlists-page-2.json",
+	})
+	defer done()
+
+	pl, err := ytr.FindPlaylist("Found Songs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pl == nil || pl.ID != "PLbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb" {
+		t.Errorf("expected playlist on the second page: %+v", pl)
+	}
+}
+
+func TestYouTubeCreatePlaylistAndAddTracks(t *testing.T) {
+	ytr, _, done := newYouTubeRepository(t, map[string]string{
+		"POST /playlists?part=snippet,status": "insert-playlist.json",
+		"POST /playlistItems?part=snippet":    "insert-playlist-item.json",
+	})
+	defer done()
+
+	pl, err := ytr.CreatePlaylist("New Songs", models.PlaylistOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pl.ID != "PLcccccccccccccccccccccccccccccccc" || pl.Name != "New Songs" {
+		t.Errorf("unexpected playlist created: %+v", pl)
+	}
+
+	batches, err := ytr.AddTracks(pl, []models.Track{{ID: "topicAudio1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 1 || batches[0].SnapshotID != "UExjY2NjY2NjY2NjLnRvcGljQXVkaW8x" {
+		t.Errorf("expected the playlist item to be returned as a batch: %+v", batches)
+	}
+}
+
+func TestYouTubeQuotaExceeded(t *testing.T) {
+	ytr, _, done := newYouTubeRepository(t, map[string]string{
+		"POST /playlists?part=snippet,status": "forbidden.json",
+	})
+	defer done()
+
+	if _, err := ytr.CreatePlaylist("New Songs", models.PlaylistOptions{}); err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("expected a quota error: %v", err)
+	}
+}
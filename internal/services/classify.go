@@ -0,0 +1,200 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"time"
+
+	// register the formats screenshots are decoded from
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+// Classifiers used to decide which screenshots are sent for text
+// detection
+const (
+	ClassifierLabels = "labels"
+	ClassifierLocal  = "local"
+	ClassifierNone   = "none"
+)
+
+const (
+	classifySamples = 64
+	musicThreshold  = 0.5
+
+	// weights of each heuristic of the local classifier, where any two
+	// reach musicThreshold so the flat screens of apps without a known
+	// layout count as music but a phone-shaped photo does not
+	weightAspect = 0.3
+	weightFlat   = 0.25
+	weightLayout = 0.45
+)
+
+// musicLabels are the Vision labels describing screenshots of the apps
+// songs are found with, rather than photos
+var musicLabels = map[string]bool{
+	"album cover":      true,
+	"brand":            true,
+	"display device":   true,
+	"electric blue":    true,
+	"font":             true,
+	"graphic design":   true,
+	"multimedia":       true,
+	"music":            true,
+	"music artist":     true,
+	"operating system": true,
+	"screenshot":       true,
+	"software":         true,
+	"technology":       true,
+	"text":             true,
+	"web page":         true,
+}
+
+// playerLayout is the dominant background colour of the screen a known
+// app shows when it recognizes or plays a song
+type playerLayout struct {
+	b, g, r   int
+	name      string
+	tolerance int
+}
+
+var playerLayouts = []playerLayout{
+	{name: "Shazam", r: 8, g: 132, b: 255, tolerance: 48},
+	{name: "SoundHound", r: 242, g: 103, b: 34, tolerance: 40},
+	{name: "Spotify", r: 18, g: 18, b: 18, tolerance: 10},
+}
+
+// classify decides whether the screenshot is probably a music screenshot
+// using the local heuristics or the Vision labels of the image
+func (ss *screenshotService) classify(s *models.Screenshot, classifier string) (models.Classification, error) {
+	if classifier == ClassifierLabels {
+		labels, err := (*ss.screenshotRepository).DetectLabels(s.Path)
+		if err != nil {
+			return models.Classification{}, err
+		}
+
+		return classifyLabels(labels), nil
+	}
+
+	return classifyImage(s.Path)
+}
+
+// classifyImage scores the image with cheap local heuristics: whether
+// its shape is that of a phone screen, whether it is made of the flat
+// colours of an app rather than a photo and whether its dominant colour
+// is that of a known player layout
+func classifyImage(path string) (models.Classification, error) {
+	c := models.Classification{
+		Classified: time.Now(),
+		Classifier: ClassifierLocal,
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return c, err
+	}
+
+	var reasons []string
+
+	b := img.Bounds()
+	long, short := b.Dy(), b.Dx()
+	if short > long {
+		long, short = short, long
+	}
+
+	// phone screens are between 16:9 and 20:9, camera photos are 4:3 or 3:2
+	if ratio := float64(long) / float64(short); ratio >= 1.7 && ratio <= 2.3 {
+		c.Score += weightAspect
+		reasons = append(reasons, "screen aspect ratio")
+	}
+
+	// sample the image in a grid, quantizing each colour so the flat
+	// backgrounds of an app fall into a few buckets
+	buckets := map[[3]int]int{}
+	for y := 0; y < classifySamples; y++ {
+		for x := 0; x < classifySamples; x++ {
+			r, g, bl, _ := img.At(
+				b.Min.X+x*b.Dx()/classifySamples,
+				b.Min.Y+y*b.Dy()/classifySamples).RGBA()
+			buckets[[3]int{int(r >> 12), int(g >> 12), int(bl >> 12)}]++
+		}
+	}
+
+	var dominant [3]int
+	top := make([]int, 3)
+	for k, n := range buckets {
+		if n > top[0] {
+			dominant = k
+		}
+
+		for i := range top {
+			if n > top[i] {
+				copy(top[i+1:], top[i:len(top)-1])
+				top[i] = n
+				break
+			}
+		}
+	}
+
+	if flat := float64(top[0]+top[1]+top[2]) / float64(classifySamples*classifySamples); flat >= 0.5 {
+		c.Score += weightFlat
+		reasons = append(reasons, "flat colours")
+	}
+
+	// the centre of the dominant bucket approximates its colour
+	r, g, bl := dominant[0]<<4+8, dominant[1]<<4+8, dominant[2]<<4+8
+	for _, l := range playerLayouts {
+		if abs(r-l.r) <= l.tolerance && abs(g-l.g) <= l.tolerance && abs(bl-l.b) <= l.tolerance {
+			c.Score += weightLayout
+			reasons = append(reasons, fmt.Sprintf("%s layout", l.name))
+			break
+		}
+	}
+
+	c.Music = c.Score >= musicThreshold
+	c.Reason = strings.Join(reasons, ", ")
+	if c.Reason == "" {
+		c.Reason = "no screenshot features"
+	}
+
+	return c, nil
+}
+
+// classifyLabels decides whether the image is a music screenshot from
+// the labels detected by the Google Cloud vision API
+func classifyLabels(labels []models.Label) models.Classification {
+	c := models.Classification{
+		Classified: time.Now(),
+		Classifier: ClassifierLabels,
+		Reason:     "no music labels",
+	}
+
+	for _, l := range labels {
+		if musicLabels[strings.ToLower(l.Description)] && l.Score > c.Score {
+			c.Reason = l.Description
+			c.Score = l.Score
+		}
+	}
+
+	c.Music = c.Score >= musicThreshold
+
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
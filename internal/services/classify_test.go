@@ -0,0 +1,230 @@
+package services_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+type classifyScreenshotRepository struct {
+	detected    []string
+	labels      map[string][]models.Label
+	screenshots []*models.Screenshot
+}
+
+func (r *classifyScreenshotRepository) DetectLabels(path string) ([]models.Label, error) {
+	return r.labels[filepath.Base(path)], nil
+}
+
+func (r *classifyScreenshotRepository) DetectText(path string) (string, error) {
+	r.detected = append(r.detected, filepath.Base(path))
+	return "Chemicals\nSG Lewis", nil
+}
+
+func (r *classifyScreenshotRepository) FindInPath(path string, opts models.DiscoveryOptions) ([]*models.Screenshot, error) {
+	return r.screenshots, nil
+}
+
+func writeClassifyImage(t *testing.T, dir string, name string, width int, height int, fill func(x, y int) color.Color) *models.Screenshot {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.Screenshot{Path: path, SHASum: name}
+}
+
+func newClassifyScreenshots(t *testing.T, dir string) []*models.Screenshot {
+	shazam := color.RGBA{R: 10, G: 130, B: 250, A: 255}
+	rnd := rand.New(rand.NewSource(1))
+
+	return []*models.Screenshot{
+		// a Shazam result with the song in white text on a blue background
+		writeClassifyImage(t, dir, "shazam.png", 390, 844, func(x, y int) color.Color {
+			if y > 400 && y < 460 && x > 40 && x < 350 {
+				return color.White
+			}
+
+			return shazam
+		}),
+		// a photo with the shape of a phone screen
+		writeClassifyImage(t, dir, "portrait.png", 390, 844, func(x, y int) color.Color {
+			return color.RGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: 255}
+		}),
+		// a photo with no flat regions and the shape of a camera sensor
+		writeClassifyImage(t, dir, "photo.png", 400, 300, func(x, y int) color.Color {
+			return color.RGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: 255}
+		}),
+	}
+}
+
+func TestScanClassifiesBeforeDetectingText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ssr                                         = &classifyScreenshotRepository{screenshots: newClassifyScreenshots(t, dir)}
+		repository interfaces.IScreenshotRepository = ssr
+		str        interfaces.IStateRepository      = &memoryStateRepository{}
+		svc                                         = services.NewScreenshotService(&repository, nil, &str, nil, nil)
+	)
+
+	state, err := svc.Scan(dir, false, models.DiscoveryOptions{Classifier: services.ClassifierLocal})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ssr.detected) != 1 || ssr.detected[0] != "shazam.png" {
+		t.Errorf("expected text to be detected only in the music screenshot: %v", ssr.detected)
+	}
+
+	music := state.Screenshots["shazam.png"].Classification
+	if music == nil || !music.Music || music.Reason != "screen aspect ratio, flat colours, Shazam layout" {
+		t.Errorf("expected the Shazam screenshot to be classified as music: %+v", music)
+	}
+
+	portrait := state.Screenshots["portrait.png"].Classification
+	if portrait == nil || portrait.Music || portrait.Reason != "screen aspect ratio" {
+		t.Errorf("expected the phone-shaped photo to be classified as not music: %+v", portrait)
+	}
+
+	photo := state.Screenshots["photo.png"]
+	if photo.Classification == nil || photo.Classification.Music || photo.Text != "" {
+		t.Errorf("expected the photo to be classified as not music: %+v", photo)
+	}
+
+	// the verdict recorded in state is used by subsequent scans
+	if _, err := svc.Scan(dir, false, models.DiscoveryOptions{Classifier: services.ClassifierLocal}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ssr.detected) != 1 {
+		t.Errorf("expected the photo to be skipped again: %v", ssr.detected)
+	}
+}
+
+func TestScanClassifiesWithLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ssr = &classifyScreenshotRepository{
+			labels: map[string][]models.Label{
+				"photo.png":  {{Description: "Sky", Score: 0.95}, {Description: "Text", Score: 0.2}},
+				"shazam.png": {{Description: "Font", Score: 0.85}, {Description: "Screenshot", Score: 0.8}},
+			},
+			screenshots: newClassifyScreenshots(t, dir),
+		}
+		repository interfaces.IScreenshotRepository = ssr
+		str        interfaces.IStateRepository      = &memoryStateRepository{}
+	)
+
+	state, err := services.NewScreenshotService(&repository, nil, &str, nil, nil).Scan(
+		dir,
+		false,
+		models.DiscoveryOptions{Classifier: services.ClassifierLabels})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ssr.detected) != 1 || ssr.detected[0] != "shazam.png" {
+		t.Errorf("expected text to be detected only in the music screenshot: %v", ssr.detected)
+	}
+
+	if c := state.Screenshots["shazam.png"].Classification; c == nil || c.Reason != "Font" {
+		t.Errorf("expected the screenshot to be classified by its labels: %+v", c)
+	}
+}
+
+// layoutScreenshot draws a phone screenshot of the background colour
+// with the artwork, when one is supplied, above rows of text
+func layoutScreenshot(background color.Color, artwork func(x, y int) color.Color) func(x, y int) color.Color {
+	return func(x, y int) color.Color {
+		switch {
+		case artwork != nil && x >= 45 && x < 345 && y >= 150 && y < 450:
+			return artwork(x, y)
+		case y >= 500 && y < 700 && y%50 < 20 && x >= 45 && x < 300:
+			return color.RGBA{R: 128, G: 128, B: 128, A: 255}
+		}
+
+		return background
+	}
+}
+
+func TestScanClassifiesEachSupportedLayout(t *testing.T) {
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+	noise := func(x, y int) color.Color {
+		return color.RGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: 255}
+	}
+
+	layouts := map[string]func(x, y int) color.Color{
+		"apple-music.png":  layoutScreenshot(color.White, noise),
+		"linn.png":         layoutScreenshot(color.RGBA{R: 40, G: 40, B: 44, A: 255}, noise),
+		"lock-screen.png":  layoutScreenshot(color.RGBA{R: 96, G: 72, B: 110, A: 255}, nil),
+		"pandora.png":      layoutScreenshot(color.RGBA{R: 240, G: 244, B: 250, A: 255}, noise),
+		"prp.png":          layoutScreenshot(color.White, nil),
+		"shazam.png":       layoutScreenshot(color.RGBA{R: 8, G: 132, B: 255, A: 255}, noise),
+		"sonos-radio.png":  layoutScreenshot(color.RGBA{R: 20, G: 20, B: 20, A: 255}, noise),
+		"soundhound.png":   layoutScreenshot(color.RGBA{R: 242, G: 103, B: 34, A: 255}, noise),
+		"spotify.png":      layoutScreenshot(color.RGBA{R: 18, G: 18, B: 18, A: 255}, noise),
+		"spotify-lock.png": layoutScreenshot(color.Black, noise),
+	}
+
+	var screenshots []*models.Screenshot
+	for name, fill := range layouts {
+		screenshots = append(screenshots, writeClassifyImage(t, dir, name, 390, 844, fill))
+	}
+
+	var (
+		ssr                                         = &classifyScreenshotRepository{screenshots: screenshots}
+		repository interfaces.IScreenshotRepository = ssr
+		str        interfaces.IStateRepository      = &memoryStateRepository{}
+	)
+
+	state, err := services.NewScreenshotService(&repository, nil, &str, nil, nil).Scan(
+		dir,
+		false,
+		models.DiscoveryOptions{Classifier: services.ClassifierLocal})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range layouts {
+		if c := state.Screenshots[name].Classification; c == nil || !c.Music {
+			t.Errorf("expected the %s screenshot to be classified as music: %+v", name, c)
+		}
+	}
+
+	if len(ssr.detected) != len(layouts) {
+		t.Errorf("expected text to be detected in each screenshot: %v", ssr.detected)
+	}
+}
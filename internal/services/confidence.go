@@ -0,0 +1,105 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// penalties applied to the confidence when the version of the track
+// differs from the version hinted at by the title in the screenshot
+const (
+	penaltyEdited     = 0.9
+	penaltyLive       = 0.8
+	penaltyRemastered = 0.95
+)
+
+// matchConfidence scores how well the track found with the
+// music provider matches the search term, as the portion of words from the
+// track's artists and name that appear in the search term or the featured
+// artists of the song. Words missing from the end of a truncated title
+// are ignored and a track of a different version scores lower
+func matchConfidence(searchTerm string, track models.Track, hints models.TitleHints) float64 {
+	if track.URI == "" {
+		return 0
+	}
+
+	terms := map[string]bool{}
+	for _, w := range words(searchTerm) {
+		terms[w] = true
+	}
+
+	for _, a := range hints.Featured {
+		for _, w := range words(a) {
+			terms[w] = true
+		}
+	}
+
+	var trackWords []string
+	for _, a := range track.Artists {
+		trackWords = append(trackWords, words(a)...)
+	}
+
+	name, trackHints := normalizeTitle(track.Name)
+	nameWords := words(name)
+
+	// the rest of a truncated title is unknown
+	if hints.Truncated {
+		for len(nameWords) > 1 && !terms[nameWords[len(nameWords)-1]] {
+			nameWords = nameWords[:len(nameWords)-1]
+		}
+	}
+
+	trackWords = append(trackWords, nameWords...)
+
+	if len(trackWords) == 0 {
+		return 0
+	}
+
+	found := 0
+	for _, w := range trackWords {
+		if terms[w] {
+			found++
+		}
+	}
+
+	confidence := float64(found) / float64(len(trackWords))
+
+	if hints.Edited != trackHints.Edited {
+		confidence *= penaltyEdited
+	}
+
+	if hints.Live != trackHints.Live {
+		confidence *= penaltyLive
+	}
+
+	if hints.Remastered != trackHints.Remastered {
+		confidence *= penaltyRemastered
+	}
+
+	return confidence
+}
+
+// words splits the text into lowercase words of letters and digits,
+// without diacritics so "Beyoncé" and "Beyonce" are the same word
+func words(text string) []string {
+	return strings.FieldsFunc(foldDiacritics(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// foldDiacritics removes the accents and other combining marks from
+// the letters of the text
+func foldDiacritics(text string) string {
+	var b strings.Builder
+
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return norm.NFC.String(b.String())
+}
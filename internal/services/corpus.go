@@ -0,0 +1,291 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+var fixtureName = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+type corpusService struct {
+	corpusRepository     *interfaces.ICorpusRepository
+	musicProvider        *interfaces.IMusicProvider
+	screenshotRepository *interfaces.IScreenshotRepository
+	screenshotService    *interfaces.IScreenshotService
+	stateRepository      *interfaces.IStateRepository
+}
+
+// NewCorpusService returns new instance of an ICorpusService
+func NewCorpusService(
+	cr *interfaces.ICorpusRepository,
+	mp *interfaces.IMusicProvider,
+	ssr *interfaces.IScreenshotRepository,
+	ss *interfaces.IScreenshotService,
+	str *interfaces.IStateRepository) interfaces.ICorpusService {
+
+	return &corpusService{
+		corpusRepository:     cr,
+		musicProvider:        mp,
+		screenshotRepository: ssr,
+		screenshotService:    ss,
+		stateRepository:      str,
+	}
+}
+
+// Add captures a fixture from the text detected in the image by a
+// previous scan. The name, artist, title and source of the fixture
+// default to the name of the image, the matched track and the app the
+// text was detected to be from when they aren't supplied. When record
+// is true the fixture is matched with the music provider and the
+// responses are recorded with the fixture for evaluating the matcher
+func (cs *corpusService) Add(path string, fixture models.Fixture, record bool) (models.Fixture, error) {
+	sss, err := (*cs.screenshotRepository).FindInPath(path, models.DiscoveryOptions{})
+	if err != nil {
+		return fixture, err
+	}
+
+	if len(sss) != 1 {
+		return fixture, fmt.Errorf("%s is not an image", path)
+	}
+
+	state, err := loadState(*cs.stateRepository)
+	if err != nil {
+		return fixture, err
+	}
+
+	s, ok := state.Screenshots[sss[0].SHASum]
+	if !ok || s.Text == "" {
+		return fixture, fmt.Errorf("no text has been detected in %s, scan it first", path)
+	}
+
+	fixture.Annotation = s.Text
+
+	if fixture.Name == "" {
+		base := filepath.Base(path)
+		fixture.Name = strings.Trim(
+			fixtureName.ReplaceAllString(strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base))), "-"),
+			"-")
+	}
+
+	if fixture.Artist == "" && fixture.Title == "" && s.Track.URI != "" {
+		fixture.Artist = strings.Join(s.Track.Artists, ", ")
+		fixture.Title = s.Track.Name
+	}
+
+	if fixture.Artist == "" || fixture.Title == "" {
+		return fixture, fmt.Errorf("%s has not been matched to a track, supply the expected artist and title", path)
+	}
+
+	if fixture.Source == "" {
+		fixture.Source = detectSource(s.Text)
+	}
+
+	if record {
+		mp := newRecordingMusicProvider(cs.musicProvider, &fixture.Responses)
+		if _, err := matchFixture(fixture, mp); err != nil {
+			return fixture, err
+		}
+	}
+
+	return fixture, (*cs.corpusRepository).Add(fixture)
+}
+
+// Evaluate parses and matches the annotation of each fixture in the
+// corpus, searching the responses recorded with the fixture, and
+// returns the precision and recall of parsing and searching for each
+// source. A fixture is parsed correctly when each word of the expected
+// artist and title is in the search term, and searched correctly when
+// they are the artists and name of the matched track
+func (cs *corpusService) Evaluate() (models.EvalReport, error) {
+	report := models.EvalReport{}
+
+	fixtures, err := (*cs.corpusRepository).Fixtures()
+	if err != nil {
+		return report, err
+	}
+
+	sources := map[string]*models.SourceEvaluation{}
+	for _, f := range fixtures {
+		se, ok := sources[f.Source]
+		if !ok {
+			se = &models.SourceEvaluation{Source: f.Source}
+			sources[f.Source] = se
+		}
+
+		s, err := matchFixture(f, newRecordedMusicProvider(f.Responses))
+		if err != nil {
+			return report, err
+		}
+
+		st := (*cs.screenshotService).SearchTerm(f.Annotation)
+		parsed := st.Parsed() || len(s.Songs) > 0
+		parsedOK := parsed && (parsedCorrectly(f, st) || listedCorrectly(f, s.Songs))
+		evaluateStage(&se.Parse, &report.Total.Parse, f, parsed, parsedOK)
+
+		matched := s.Status == models.StatusMatched
+		evaluateStage(&se.Search, &report.Total.Search, f, matched, matched && matchedCorrectly(f, s.Track))
+	}
+
+	for _, se := range sources {
+		report.Sources = append(report.Sources, *se)
+	}
+
+	sort.Slice(report.Sources, func(i, j int) bool {
+		return report.Sources[i].Source < report.Sources[j].Source
+	})
+
+	return report, nil
+}
+
+// Report parses the annotation of each fixture in the corpus and
+// returns the accuracy of the parser for each source, where a fixture
+// is parsed correctly when each word of the expected artist and title
+// is in the search term
+func (cs *corpusService) Report() (models.CorpusReport, error) {
+	report := models.CorpusReport{}
+
+	fixtures, err := (*cs.corpusRepository).Fixtures()
+	if err != nil {
+		return report, err
+	}
+
+	sources := map[string]*models.SourceAccuracy{}
+	for _, f := range fixtures {
+		sa, ok := sources[f.Source]
+		if !ok {
+			sa = &models.SourceAccuracy{Source: f.Source}
+			sources[f.Source] = sa
+		}
+
+		sa.Fixtures++
+		report.Total.Fixtures++
+
+		if parsedCorrectly(f, (*cs.screenshotService).SearchTerm(f.Annotation)) {
+			sa.Correct++
+			report.Total.Correct++
+			continue
+		}
+
+		sa.Failures = append(sa.Failures, f.Name)
+		report.Total.Failures = append(report.Total.Failures, f.Name)
+	}
+
+	for _, sa := range sources {
+		report.Sources = append(report.Sources, *sa)
+	}
+
+	sort.Slice(report.Sources, func(i, j int) bool {
+		return report.Sources[i].Source < report.Sources[j].Source
+	})
+
+	return report, nil
+}
+
+// evaluateStage counts the fixture in the accuracy of the stage for
+// the source and in total, where found is true when the stage found a
+// song and correct when it was the expected song
+func evaluateStage(source *models.StageAccuracy, total *models.StageAccuracy, f models.Fixture, found bool, correct bool) {
+	for _, sa := range []*models.StageAccuracy{source, total} {
+		if f.HasSong() {
+			sa.Expected++
+		}
+
+		if found {
+			sa.Predicted++
+		}
+
+		if correct && f.HasSong() {
+			sa.Correct++
+			continue
+		}
+
+		if found || f.HasSong() {
+			sa.Failures = append(sa.Failures, f.Name)
+		}
+	}
+}
+
+// listedCorrectly returns true when the expected song of the fixture
+// is one of the songs listed in the screenshot
+func listedCorrectly(f models.Fixture, songs []models.Song) bool {
+	for _, song := range songs {
+		if parsedCorrectly(f, models.SearchTerm{Confidence: confidenceList, Term: song.SearchTerm}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchFixture matches the annotation of the fixture the same way as
+// a screenshot, searching the music provider without verifying songs
+// with a metadata repository or correcting them with known artists so
+// the responses recorded for a fixture are searched for again
+func matchFixture(f models.Fixture, mp interfaces.IMusicProvider) (models.Screenshot, error) {
+	ss := &screenshotService{musicProvider: &mp}
+	s := models.Screenshot{
+		Path:   f.Name,
+		SHASum: f.Name,
+		Text:   f.Annotation,
+	}
+
+	err := ss.matchScreenshot(&s, newSpellDictionary(nil))
+
+	return s, err
+}
+
+// matchedCorrectly returns true when the expected artist and title of
+// the fixture are the artists and name of the track
+func matchedCorrectly(f models.Fixture, track models.Track) bool {
+	if !f.HasSong() {
+		return false
+	}
+
+	artists := map[string]bool{}
+	for _, w := range words(strings.Join(track.Artists, " ")) {
+		artists[w] = true
+	}
+
+	for _, w := range words(f.Artist) {
+		if !artists[w] {
+			return false
+		}
+	}
+
+	// version details of either title don't make it another song
+	expected, _ := normalizeTitle(f.Title)
+	name, _ := normalizeTitle(track.Name)
+
+	return strings.Join(words(expected), " ") == strings.Join(words(name), " ")
+}
+
+// parsedCorrectly returns true when each word of the expected artist
+// and title of the fixture is in the search term, or when the fixture
+// has no song and none was parsed
+func parsedCorrectly(f models.Fixture, st models.SearchTerm) bool {
+	if !f.HasSong() {
+		return !st.Parsed()
+	}
+
+	terms := map[string]bool{}
+	for _, w := range words(st.Term) {
+		terms[w] = true
+	}
+
+	// the version details of the title aren't searched for
+	title, _ := normalizeTitle(f.Title)
+
+	for _, w := range words(f.Artist + " " + title) {
+		if !terms[w] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,235 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/repositories"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+const corpusPath = "testdata/corpus"
+
+func TestCorpus(t *testing.T) {
+	fixtures, err := repositories.NewCorpusRepository(corpusPath).Fixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures) == 0 {
+		t.Fatalf("expected fixtures in %s", corpusPath)
+	}
+
+	for _, f := range fixtures {
+		f := f
+		t.Run(f.Name, func(t *testing.T) {
+			if f.Term == "" {
+				t.Skip("no expected search term")
+			}
+
+			if song := s.SearchTerm(f.Annotation).Term; song != f.Term {
+				t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", f.Term, song)
+			}
+		})
+	}
+}
+
+func TestCorpusReport(t *testing.T) {
+	var (
+		cr                                = repositories.NewCorpusRepository(corpusPath)
+		svc interfaces.IScreenshotService = s
+	)
+
+	report, err := services.NewCorpusService(&cr, nil, nil, &svc, nil).Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sa := range report.Sources {
+		t.Logf("%-24s %3d/%-3d %6.1f%%", sa.Source, sa.Correct, sa.Fixtures, sa.Accuracy()*100)
+	}
+
+	if len(report.Total.Failures) > 0 {
+		t.Errorf("expected the artist and title to be parsed from every fixture: %v", report.Total.Failures)
+	}
+}
+
+func TestCorpusAdd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		cr                                   = repositories.NewCorpusRepository(dir)
+		ssr interfaces.IScreenshotRepository = &classifyScreenshotRepository{
+			screenshots: []*models.Screenshot{{Path: "IMG 0042.PNG", SHASum: "a"}},
+		}
+		str interfaces.IStateRepository   = &memoryStateRepository{}
+		svc interfaces.IScreenshotService = s
+	)
+
+	if err := str.Save(models.State{
+		Screenshots: map[string]*models.Screenshot{
+			"a": {
+				Path:   "IMG 0042.PNG",
+				SHASum: "a",
+				Text:   "Portland Radio Project\nThe Dig - Soul of the Night\n",
+				Track:  models.Track{Artists: []string{"The Dig"}, Name: "Soul of the Night", URI: "spotify:track:dig"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := services.NewCorpusService(&cr, nil, &ssr, &svc, &str)
+
+	f, err := cs.Add("IMG 0042.PNG", models.Fixture{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Name != "img-0042" || f.Artist != "The Dig" || f.Title != "Soul of the Night" || f.Source != "Radio" {
+		t.Errorf("expected the fixture to be labeled from the matched track: %+v", f)
+	}
+
+	fixtures, err := cr.Fixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures) != 1 || fixtures[0].Annotation != f.Annotation || fixtures[0].Artist != "The Dig" {
+		t.Errorf("expected the fixture to be written to the corpus: %+v", fixtures)
+	}
+
+	if _, err := cs.Add("IMG 0042.PNG", models.Fixture{}, false); err == nil {
+		t.Error("expected an error adding a fixture that already exists")
+	}
+
+	var provider interfaces.IMusicProvider = &fakeSpotifyRepository{
+		catalog: []models.Track{
+			{Artists: []string{"The Dig"}, Name: "Soul of the Night", URI: "spotify:track:dig"},
+		},
+	}
+
+	cs = services.NewCorpusService(&cr, &provider, &ssr, &svc, &str)
+	if _, err := cs.Add("IMG 0042.PNG", models.Fixture{Name: "recorded"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if fixtures, err = cr.Fixtures(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tracks := fixtures[1].Responses.Search["the dig soul of the night"]; fixtures[1].Name != "recorded" || len(tracks) != 1 || tracks[0].URI != "spotify:track:dig" {
+		t.Errorf("expected the search responses to be recorded with the fixture: %+v", fixtures[1])
+	}
+}
+
+func TestCorpusEvaluate(t *testing.T) {
+	var (
+		cr                                = repositories.NewCorpusRepository(corpusPath)
+		svc interfaces.IScreenshotService = s
+	)
+
+	report, err := services.NewCorpusService(&cr, nil, nil, &svc, nil).Evaluate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, se := range report.Sources {
+		t.Logf(
+			"%-24s parse %5.1f%%/%5.1f%% search %5.1f%%/%5.1f%%",
+			se.Source,
+			se.Parse.Precision()*100,
+			se.Parse.Recall()*100,
+			se.Search.Precision()*100,
+			se.Search.Recall()*100)
+	}
+
+	if len(report.Total.Parse.Failures) > 0 || len(report.Total.Search.Failures) > 0 {
+		t.Errorf("expected every fixture to be parsed and searched correctly: %+v", report.Total)
+	}
+}
+
+func TestCorpusEvaluateCountsWrongSongs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		cr                                = repositories.NewCorpusRepository(dir)
+		svc interfaces.IScreenshotService = s
+	)
+
+	for _, f := range []models.Fixture{
+		{
+			Annotation: "Portland Radio Project\nThe Dig - Soul of the Night\n",
+			Artist:     "The Dig",
+			Name:       "cover",
+			Responses: models.RecordedResponses{
+				Search: map[string][]models.Track{
+					"the dig soul of the night": {{Artists: []string{"Tribute Band"}, Name: "Soul of the Night", URI: "spotify:track:cover"}},
+				},
+			},
+			Source: "Radio",
+			Title:  "Soul of the Night",
+		},
+		{
+			Annotation: "Portland Radio Project\nThe Dig - Soul of the Night (Live)\n",
+			Artist:     "The Dig",
+			Name:       "live",
+			Responses: models.RecordedResponses{
+				Search: map[string][]models.Track{
+					"the dig soul of the night": {{Artists: []string{"The Dig"}, Name: "Soul of the Night - Live", URI: "spotify:track:live"}},
+				},
+			},
+			Source: "Radio",
+			Title:  "Soul of the Night (Live)",
+		},
+		{
+			Annotation: "Portland Radio Project\nThe Dig - Bones\n",
+			Artist:     "The Dig",
+			Name:       "unrecorded",
+			Source:     "Radio",
+			Title:      "Bones",
+		},
+		{
+			Annotation: "Nora - Are we still on for dinner tonight\n",
+			Name:       "not-a-song",
+			Source:     "Radio",
+		},
+	} {
+		if err := cr.Add(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := services.NewCorpusService(&cr, nil, nil, &svc, nil).Evaluate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Sources) != 1 || report.Sources[0].Source != "Radio" {
+		t.Fatalf("expected a single source: %+v", report.Sources)
+	}
+
+	parse, search := report.Total.Parse, report.Total.Search
+	if parse.Expected != 3 || parse.Predicted != 4 || parse.Correct != 3 {
+		t.Errorf("expected the divider in the message to be parsed as a song: %+v", parse)
+	}
+
+	if search.Expected != 3 || search.Predicted != 2 || search.Correct != 1 {
+		t.Errorf("expected the cover to be searched incorrectly and the unrecorded song not found: %+v", search)
+	}
+
+	if search.Precision() != 0.5 || search.Recall() != 1.0/3 {
+		t.Errorf("expected search precision of 50%% and recall of 33%%: %.2f %.2f", search.Precision(), search.Recall())
+	}
+}
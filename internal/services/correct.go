@@ -0,0 +1,243 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// names shorter than minCorrectionLength aren't corrected and those
+// shorter than minDoubleEditLength are corrected by a single edit, so
+// short words aren't corrected to other short words
+const (
+	maxCorrectionDistance = 2
+	minCorrectionLength   = 4
+	minDoubleEditLength   = 8
+)
+
+var (
+	// homoglyphs are the Cyrillic and Greek letters OCR commonly reads
+	// in place of the Latin letters they look like
+	homoglyphs = map[rune]rune{
+		// Cyrillic
+		'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+		'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J',
+		'а': 'a', 'е': 'e', 'к': 'k', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y',
+		'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j',
+		// Greek
+		'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+		'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+		'ο': 'o', 'ν': 'v',
+	}
+
+	letters = regexp.MustCompile(`\pL+`)
+)
+
+// foldHomoglyphs replaces the Cyrillic and Greek letters of words that
+// also contain Latin letters with the Latin letters they look like,
+// leaving words written entirely in another script as they are
+func foldHomoglyphs(text string) string {
+	return letters.ReplaceAllStringFunc(text, func(word string) string {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.Is(unicode.Latin, r) }) < 0 {
+			return word
+		}
+
+		return strings.Map(func(r rune) rune {
+			if l, ok := homoglyphs[r]; ok {
+				return l
+			}
+
+			return r
+		}, word)
+	})
+}
+
+// spellDictionary corrects misread names using the symmetric delete
+// algorithm of SymSpell, where a name and the text to correct are
+// candidates for each other when deleting characters from each leaves
+// the same string
+type spellDictionary struct {
+	counts  map[string]int
+	deletes map[string][]string
+	lengths []int
+}
+
+// newSpellDictionary returns a dictionary of the names, where names
+// occurring more often are preferred when correcting
+func newSpellDictionary(names []string) *spellDictionary {
+	d := &spellDictionary{
+		counts:  map[string]int{},
+		deletes: map[string][]string{},
+	}
+
+	lengths := map[int]bool{}
+	for _, n := range names {
+		n = strings.Join(strings.Fields(strings.ToLower(n)), " ")
+		if n == "" {
+			continue
+		}
+
+		if d.counts[n]++; d.counts[n] > 1 {
+			continue
+		}
+
+		lengths[len(strings.Fields(n))] = true
+		for del := range deletes([]rune(n), maxCorrectionDistance) {
+			d.deletes[del] = append(d.deletes[del], n)
+		}
+	}
+
+	for l := range lengths {
+		d.lengths = append(d.lengths, l)
+	}
+
+	// prefer correcting the names with the most words
+	sort.Sort(sort.Reverse(sort.IntSlice(d.lengths)))
+
+	return d
+}
+
+// correctArtist replaces the words the text starts with, which are
+// the artist of the song, with the name in the dictionary within the
+// allowed edit distance of them. The words of the title that follow
+// are left alone so titles such as "Blue" aren't corrected to an
+// artist such as "Blur"
+func (d *spellDictionary) correctArtist(text string) string {
+	words := strings.Fields(text)
+
+	for _, l := range d.lengths {
+		if l > len(words) {
+			continue
+		}
+
+		if name, ok := d.lookup(strings.Join(words[:l], " ")); ok {
+			return strings.Join(append([]string{name}, words[l:]...), " ")
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// lookup returns the name closest to the phrase, preferring the name
+// occurring most often when more than one is as close
+func (d *spellDictionary) lookup(phrase string) (string, bool) {
+	if d.counts[phrase] > 0 {
+		return phrase, true
+	}
+
+	runes := []rune(phrase)
+	allowed := correctionDistance(len(runes))
+	if allowed == 0 {
+		return "", false
+	}
+
+	var (
+		best         string
+		bestDistance int
+		seen         = map[string]bool{}
+	)
+
+	for del := range deletes(runes, allowed) {
+		for _, n := range d.deletes[del] {
+			if seen[n] {
+				continue
+			}
+
+			seen[n] = true
+
+			distance := editDistance(runes, []rune(n))
+			if distance > allowed {
+				continue
+			}
+
+			if best == "" ||
+				distance < bestDistance ||
+				distance == bestDistance && d.counts[n] > d.counts[best] ||
+				distance == bestDistance && d.counts[n] == d.counts[best] && n < best {
+				best = n
+				bestDistance = distance
+			}
+		}
+	}
+
+	return best, best != ""
+}
+
+func correctionDistance(length int) int {
+	switch {
+	case length < minCorrectionLength:
+		return 0
+	case length < minDoubleEditLength:
+		return 1
+	default:
+		return maxCorrectionDistance
+	}
+}
+
+// deletes returns the strings left after deleting up to the distance
+// of characters from the word, including the word itself
+func deletes(word []rune, distance int) map[string]bool {
+	found := map[string]bool{string(word): true}
+	edits := [][]rune{word}
+
+	for d := 0; d < distance; d++ {
+		var next [][]rune
+
+		for _, e := range edits {
+			for i := range e {
+				del := append(append([]rune{}, e[:i]...), e[i+1:]...)
+				if s := string(del); !found[s] {
+					found[s] = true
+					next = append(next, del)
+				}
+			}
+		}
+
+		edits = next
+	}
+
+	return found
+}
+
+// editDistance returns the optimal string alignment distance between
+// the words, counting a transposition of adjacent letters as one edit
+func editDistance(a []rune, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			rows[i][j] = minInt(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = minInt(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(a)][len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
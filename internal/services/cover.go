@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	// register the formats screenshots are decoded from
+	_ "image/png"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	coverDateLayout = "2006-01-02"
+	coverMaxSize    = 256 * 1024
+	coverMinQuality = 30
+	coverSize       = 640
+	coverTiles      = 2
+)
+
+// DefaultPlaylistDescription is the description template used for
+// playlists when none is supplied
+const DefaultPlaylistDescription = "Songs found in {{.Count}} screenshots from {{.From}} to {{.To}}"
+
+type descriptionData struct {
+	Count int
+	From  string
+	To    string
+}
+
+// Cover creates a square JPEG cover image from a grid of thumbnails of
+// the screenshots, reducing the quality until the base64 encoded image
+// is no larger than the 256KB music providers accept
+func (ps playlistService) Cover(screenshots []*models.Screenshot) ([]byte, error) {
+	var thumbnails []image.Image
+	for _, s := range screenshots {
+		if len(thumbnails) == coverTiles*coverTiles {
+			break
+		}
+
+		img, err := decodeImage(s.Path)
+		if err != nil {
+			log.Debug().Err(err).Str("path", s.Path).Msg("unable to decode screenshot for cover")
+			continue
+		}
+
+		thumbnails = append(thumbnails, thumbnail(img, coverSize/coverTiles))
+	}
+
+	if len(thumbnails) == 0 {
+		return nil, errors.New("no screenshots could be decoded for the cover image")
+	}
+
+	cover := image.NewRGBA(image.Rect(0, 0, coverSize, coverSize))
+	tile := coverSize / coverTiles
+	for i := 0; i < coverTiles*coverTiles; i++ {
+		// repeat the thumbnails to fill the grid when there are fewer
+		// screenshots than tiles
+		t := thumbnails[i%len(thumbnails)]
+		x, y := (i%coverTiles)*tile, (i/coverTiles)*tile
+		draw.Draw(cover, image.Rect(x, y, x+tile, y+tile), t, t.Bounds().Min, draw.Src)
+	}
+
+	for quality := 90; quality >= coverMinQuality; quality -= 10 {
+		buf := &bytes.Buffer{}
+		if err := jpeg.Encode(buf, cover, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+
+		if base64.StdEncoding.EncodedLen(buf.Len()) <= coverMaxSize {
+			return buf.Bytes(), nil
+		}
+	}
+
+	return nil, errors.New("unable to reduce the cover image to 256KB")
+}
+
+// Description renders the description template with the number of
+// screenshots and the range of dates they were taken
+func (ps playlistService) Description(text string, screenshots []*models.Screenshot) (string, error) {
+	tmpl, err := template.New("description").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	data := descriptionData{Count: len(screenshots)}
+
+	var from, to time.Time
+	for _, s := range screenshots {
+		taken := screenshotTime(s)
+		if taken.IsZero() {
+			continue
+		}
+
+		if from.IsZero() || taken.Before(from) {
+			from = taken
+		}
+
+		if taken.After(to) {
+			to = taken
+		}
+	}
+
+	if !from.IsZero() {
+		data.From = from.Format(coverDateLayout)
+		data.To = to.Format(coverDateLayout)
+	}
+
+	sb := &strings.Builder{}
+	if err := tmpl.Execute(sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+
+	return img, err
+}
+
+// thumbnail crops the centre square of the image and scales it to the
+// size by sampling the nearest pixel
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	t := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			t.Set(x, y, img.At(x0+x*side/size, y0+y*side/size))
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+const (
+	formatCSV         = "csv"
+	formatImportCSV   = "import-csv"
+	formatJSON        = "json"
+	formatJSONL       = "jsonl"
+	formatM3U         = "m3u"
+	formatMusicBrainz = "musicbrainz"
+	formatText        = "text"
+	formatXSPF        = "xspf"
+)
+
+var importCSVHeader = []string{
+	"Artist",
+	"Title",
+	"Album",
+	"ISRC",
+}
+
+var csvHeader = []string{
+	"file",
+	"hash",
+	"search_term",
+	"artist",
+	"title",
+	"track_uri",
+	"confidence",
+	"status",
+}
+
+type exportService struct {
+	writers map[string]func(w io.Writer, sss []*models.Screenshot) error
+}
+
+// mbArtistCredit, mbRecording and mbRelease follow the shape
+// of the MusicBrainz WS/2 JSON recording resource
+type mbArtistCredit struct {
+	Name string `json:"name"`
+}
+
+type mbRecording struct {
+	ArtistCredit []mbArtistCredit `json:"artist-credit"`
+	ISRCs        []string         `json:"isrcs"`
+	Length       int              `json:"length,omitempty"`
+	Releases     []mbRelease      `json:"releases,omitempty"`
+	Title        string           `json:"title"`
+}
+
+type mbRelease struct {
+	Title string `json:"title"`
+}
+
+// record is the machine-readable representation of a screenshot
+type record struct {
+	Artist     string        `json:"artist"`
+	Captured   string        `json:"captured,omitempty"`
+	Confidence float64       `json:"confidence"`
+	Device     string        `json:"device,omitempty"`
+	File       string        `json:"file"`
+	Hash       string        `json:"hash"`
+	SearchTerm string        `json:"searchTerm"`
+	Status     models.Status `json:"status"`
+	Title      string        `json:"title"`
+	TrackURI   string        `json:"trackUri"`
+}
+
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"playlist"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns,attr"`
+	Creator   string      `xml:"creator,omitempty"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string `xml:"location"`
+	Identifier string `xml:"identifier"`
+	Creator    string `xml:"creator"`
+	Title      string `xml:"title"`
+	Duration   int    `xml:"duration,omitempty"`
+}
+
+// NewExportService returns new instance of an IExportService
+func NewExportService() interfaces.IExportService {
+	es := &exportService{}
+
+	es.writers = map[string]func(w io.Writer, sss []*models.Screenshot) error{
+		formatCSV:         es.writeCSV,
+		formatImportCSV:   es.writeImportCSV,
+		formatJSON:        es.writeJSON,
+		formatJSONL:       es.writeJSONL,
+		formatM3U:         es.writeM3U,
+		formatMusicBrainz: es.writeMusicBrainz,
+		formatText:        es.writeText,
+		formatXSPF:        es.writeXSPF,
+	}
+
+	return es
+}
+
+// Export writes the screenshots in state, ordered by path or by
+// capture time, to the writer in the specified format
+func (es *exportService) Export(w io.Writer, format string, order string, state models.State) error {
+	write, ok := es.writers[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	sss := make([]*models.Screenshot, 0, len(state.Screenshots))
+	for _, s := range state.Screenshots {
+		sss = append(sss, s)
+	}
+
+	SortScreenshots(sss, order)
+
+	return write(w, sss)
+}
+
+// Formats returns the supported output formats
+func (es *exportService) Formats() []string {
+	formats := make([]string, 0, len(es.writers))
+	for f := range es.writers {
+		formats = append(formats, f)
+	}
+
+	sort.Strings(formats)
+
+	return formats
+}
+
+func (es *exportService) writeCSV(w io.Writer, sss []*models.Screenshot) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, r := range newRecords(sss) {
+		if err := cw.Write([]string{
+			r.File,
+			r.Hash,
+			r.SearchTerm,
+			r.Artist,
+			r.Title,
+			r.TrackURI,
+			strconv.FormatFloat(r.Confidence, 'f', 2, 64),
+			string(r.Status),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func (es *exportService) writeImportCSV(w io.Writer, sss []*models.Screenshot) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(importCSVHeader); err != nil {
+		return err
+	}
+
+	for _, t := range matchedTracks(sss) {
+		if err := cw.Write([]string{
+			artistNames(t),
+			t.Name,
+			t.Album,
+			t.ISRC,
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func (es *exportService) writeJSON(w io.Writer, sss []*models.Screenshot) error {
+	records := newRecords(sss)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	return enc.Encode(records)
+}
+
+func (es *exportService) writeJSONL(w io.Writer, sss []*models.Screenshot) error {
+	enc := json.NewEncoder(w)
+
+	for _, r := range newRecords(sss) {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (es *exportService) writeM3U(w io.Writer, sss []*models.Screenshot) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+
+	for _, t := range matchedTracks(sss) {
+		// M3U uses -1 when the duration is unknown
+		seconds := -1
+		if t.DurationMS > 0 {
+			seconds = t.DurationMS / 1000
+		}
+
+		if _, err := fmt.Fprintf(
+			w,
+			"#EXTINF:%d,%s - %s\n%s\n",
+			seconds,
+			artistNames(t),
+			t.Name,
+			t.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (es *exportService) writeMusicBrainz(w io.Writer, sss []*models.Screenshot) error {
+	tracks := matchedTracks(sss)
+	recordings := make([]mbRecording, 0, len(tracks))
+
+	for _, t := range tracks {
+		r := mbRecording{
+			ISRCs:  []string{},
+			Length: t.DurationMS,
+			Title:  t.Name,
+		}
+
+		for _, a := range t.Artists {
+			r.ArtistCredit = append(r.ArtistCredit, mbArtistCredit{Name: a})
+		}
+
+		if t.ISRC != "" {
+			r.ISRCs = append(r.ISRCs, t.ISRC)
+		}
+
+		if t.Album != "" {
+			r.Releases = []mbRelease{{Title: t.Album}}
+		}
+
+		recordings = append(recordings, r)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	return enc.Encode(struct {
+		Count      int           `json:"count"`
+		Recordings []mbRecording `json:"recordings"`
+	}{
+		Count:      len(recordings),
+		Recordings: recordings,
+	})
+}
+
+func (es *exportService) writeText(w io.Writer, sss []*models.Screenshot) error {
+	for _, t := range matchedTracks(sss) {
+		if _, err := fmt.Fprintf(w, "%s - %s\n", artistNames(t), t.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (es *exportService) writeXSPF(w io.Writer, sss []*models.Screenshot) error {
+	pl := xspfPlaylist{
+		Version:   "1",
+		Namespace: "http://xspf.org/ns/0/",
+		Creator:   "song-finder",
+	}
+
+	for _, t := range matchedTracks(sss) {
+		pl.Tracks = append(pl.Tracks, xspfTrack{
+			Location:   t.URL,
+			Identifier: t.URI,
+			Creator:    artistNames(t),
+			Title:      t.Name,
+			Duration:   t.DurationMS,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+
+	if err := enc.Encode(pl); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+
+	return err
+}
+
+func artistNames(t models.Track) string {
+	return strings.Join(t.Artists, ", ")
+}
+
+func matchedTracks(sss []*models.Screenshot) []models.Track {
+	var tracks []models.Track
+
+	for _, s := range sss {
+		tracks = append(tracks, s.Tracks()...)
+	}
+
+	return tracks
+}
+
+func newRecord(s *models.Screenshot) record {
+	r := record{
+		Artist:     artistNames(s.Track),
+		Confidence: s.Confidence,
+		Device:     s.Device,
+		File:       s.Path,
+		Hash:       s.SHASum,
+		SearchTerm: s.SongSearchTerm,
+		Status:     screenshotStatus(s),
+		Title:      s.Track.Name,
+		TrackURI:   s.Track.URI,
+	}
+
+	if !s.Captured.IsZero() {
+		r.Captured = s.Captured.Format(time.RFC3339)
+	}
+
+	return r
+}
+
+// newRecords returns a record for each screenshot, or for each of
+// the songs listed in a screenshot
+func newRecords(sss []*models.Screenshot) []record {
+	records := make([]record, 0, len(sss))
+
+	for _, s := range sss {
+		r := newRecord(s)
+		if len(s.Songs) == 0 || s.Override != nil {
+			records = append(records, r)
+			continue
+		}
+
+		for _, song := range s.Songs {
+			r.Artist = artistNames(song.Track)
+			r.Confidence = song.Confidence
+			r.SearchTerm = song.SearchTerm
+			r.Status = models.StatusUnmatched
+			r.Title = song.Track.Name
+			r.TrackURI = song.Track.URI
+
+			if song.Track.URI != "" {
+				r.Status = models.StatusMatched
+			}
+
+			records = append(records, r)
+		}
+	}
+
+	return records
+}
+
+// screenshotStatus returns the status recorded for the screenshot
+// unless it has been reviewed or matched since
+func screenshotStatus(s *models.Screenshot) models.Status {
+	switch {
+	case s.Override != nil && s.Override.NotASong:
+		return models.StatusNotMusic
+	case s.Override != nil:
+		return models.StatusManuallyResolved
+	case s.Track.URI != "":
+		return models.StatusMatched
+	case s.Status != "":
+		return s.Status
+	case s.LastSearched.IsZero():
+		return models.StatusParsed
+	default:
+		return models.StatusUnmatched
+	}
+}
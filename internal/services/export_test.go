@@ -0,0 +1,234 @@
+package services_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+var exportState = models.State{
+	Screenshots: map[string]*models.Screenshot{
+		"b": {
+			Confidence:     0.75,
+			LastSearched:   time.Now(),
+			Path:           "b.png",
+			SHASum:         "b",
+			SongSearchTerm: "sg lewis chemicals",
+			Track: models.Track{
+				Album:      "Chemicals",
+				Artists:    []string{"SG Lewis"},
+				DurationMS: 215000,
+				ID:         "b1",
+				ISRC:       "GBUM72000001",
+				Name:       "Chemicals",
+				URI:        "spotify:track:b1",
+				URL:        "https://open.spotify.com/track/b1",
+			},
+		},
+		"a": {
+			LastSearched:   time.Now(),
+			Path:           "a.png",
+			SHASum:         "a",
+			SongSearchTerm: "hello, world",
+		},
+	},
+}
+
+func TestExportCSV(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := services.NewExportService().Export(&buf, "csv", services.OrderPath, exportState); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `file,hash,search_term,artist,title,track_uri,confidence,status
+a.png,a,"hello, world",,,,0.00,Unmatched
+b.png,b,sg lewis chemicals,SG Lewis,Chemicals,spotify:track:b1,0.75,Matched
+`
+	if buf.String() != expected {
+		t.Errorf("expected CSV \"%s\" was not matched: \"%s\"", expected, buf.String())
+	}
+}
+
+func TestExportM3U(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := services.NewExportService().Export(&buf, "m3u", services.OrderPath, exportState); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `#EXTM3U
+#EXTINF:215,SG Lewis - Chemicals
+https://open.spotify.com/track/b1
+`
+	if buf.String() != expected {
+		t.Errorf("expected M3U \"%s\" was not matched: \"%s\"", expected, buf.String())
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := services.NewExportService().Export(&buf, "jsonl", services.OrderPath, exportState); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"trackUri":"spotify:track:b1"`) {
+		t.Errorf("unexpected JSON lines: %s", buf.String())
+	}
+}
+
+func TestExportImportCSV(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := services.NewExportService().Export(&buf, "import-csv", services.OrderPath, exportState); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Artist,Title,Album,ISRC
+SG Lewis,Chemicals,Chemicals,GBUM72000001
+`
+	if buf.String() != expected {
+		t.Errorf("expected CSV \"%s\" was not matched: \"%s\"", expected, buf.String())
+	}
+}
+
+func TestExportText(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := services.NewExportService().Export(&buf, "text", services.OrderPath, exportState); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SG Lewis - Chemicals\n"
+	if buf.String() != expected {
+		t.Errorf("expected text \"%s\" was not matched: \"%s\"", expected, buf.String())
+	}
+}
+
+func TestExportUnsupportedFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := services.NewExportService().Export(&buf, "wav", services.OrderPath, exportState); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestExportOrderedByCaptureTime(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		state = models.State{
+			Screenshots: map[string]*models.Screenshot{
+				"a": {Captured: time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC), Path: "a.png", SHASum: "a"},
+				"b": {Captured: time.Date(2021, 1, 1, 9, 0, 0, 0, time.UTC), Device: "iPhone 12 Pro", Path: "b.png", SHASum: "b"},
+			},
+		}
+	)
+
+	if err := services.NewExportService().Export(&buf, "jsonl", services.OrderCaptured, state); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 ||
+		!strings.Contains(lines[0], `"captured":"2021-01-01T09:00:00Z"`) ||
+		!strings.Contains(lines[0], `"device":"iPhone 12 Pro"`) ||
+		!strings.Contains(lines[1], `"file":"a.png"`) {
+		t.Errorf("expected the screenshots in the order captured: %s", buf.String())
+	}
+}
+
+func TestCapturedBetween(t *testing.T) {
+	s := &models.Screenshot{Captured: time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)}
+
+	for _, c := range []struct {
+		since    time.Time
+		until    time.Time
+		expected bool
+	}{
+		{expected: true},
+		{since: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), expected: true},
+		{since: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC), expected: false},
+		{until: time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC), expected: false},
+		{
+			since:    time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+			until:    time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+	} {
+		if actual := services.CapturedBetween(s, c.since, c.until); actual != c.expected {
+			t.Errorf("expected %t between %s and %s", c.expected, c.since, c.until)
+		}
+	}
+}
+
+func TestExportCSVListedSongs(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		state = models.State{
+			Screenshots: map[string]*models.Screenshot{
+				"c": {
+					LastSearched: time.Now(),
+					Path:         "c.png",
+					SHASum:       "c",
+					Songs: []models.Song{
+						{
+							Confidence: 0.9,
+							SearchTerm: "sg lewis chemicals",
+							Status:     models.StatusMatched,
+							Track:      models.Track{Artists: []string{"SG Lewis"}, Name: "Chemicals", URI: "spotify:track:b1"},
+						},
+						{SearchTerm: "unknown untitled", Status: models.StatusUnmatched},
+					},
+				},
+			},
+		}
+	)
+
+	if err := services.NewExportService().Export(&buf, "csv", services.OrderPath, state); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `file,hash,search_term,artist,title,track_uri,confidence,status
+c.png,c,sg lewis chemicals,SG Lewis,Chemicals,spotify:track:b1,0.90,Matched
+c.png,c,unknown untitled,,,,0.00,Unmatched
+`
+	if buf.String() != expected {
+		t.Errorf("expected CSV \"%s\" was not matched: \"%s\"", expected, buf.String())
+	}
+}
+
+func TestExportCSVStatuses(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		state = models.State{
+			Screenshots: map[string]*models.Screenshot{
+				"d": {Path: "d.png", SHASum: "d", Status: models.StatusNotMusic},
+				"e": {Path: "e.png", SHASum: "e", Status: models.StatusUnparsed},
+				"f": {Path: "f.png", SHASum: "f", SongSearchTerm: "the dig soul of the night", Status: models.StatusParsed},
+				"g": {Override: &models.Override{}, Path: "g.png", SHASum: "g", Status: models.StatusUnmatched},
+				"h": {Override: &models.Override{NotASong: true}, Path: "h.png", SHASum: "h", Status: models.StatusMatched},
+			},
+		}
+	)
+
+	if err := services.NewExportService().Export(&buf, "csv", services.OrderPath, state); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `file,hash,search_term,artist,title,track_uri,confidence,status
+d.png,d,,,,,0.00,NotMusic
+e.png,e,,,,,0.00,Unparsed
+f.png,f,the dig soul of the night,,,,0.00,Parsed
+g.png,g,,,,,0.00,ManuallyResolved
+h.png,h,,,,,0.00,NotMusic
+`
+	if buf.String() != expected {
+		t.Errorf("expected CSV \"%s\" was not matched: \"%s\"", expected, buf.String())
+	}
+}
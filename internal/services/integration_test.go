@@ -0,0 +1,352 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/repositories"
+	"github.com/brozeph/song-finder/internal/services"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/api/option"
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+	"google.golang.org/grpc"
+)
+
+// fakeVision is a Google Cloud vision API backend serving the labels
+// and text of each image, identified by the SHA-256 of its content
+type fakeVision struct {
+	pb.UnimplementedImageAnnotatorServer
+	detected []string
+	labels   map[string][]string
+	texts    map[string]string
+}
+
+// newFakeVision serves the fake Vision backend for the test and returns
+// the client options to connect to it
+func newFakeVision(t *testing.T, v *fakeVision) []option.ClientOption {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	pb.RegisterImageAnnotatorServer(srv, v)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return []option.ClientOption{
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+		option.WithoutAuthentication(),
+	}
+}
+
+func (v *fakeVision) BatchAnnotateImages(ctx context.Context, req *pb.BatchAnnotateImagesRequest) (*pb.BatchAnnotateImagesResponse, error) {
+	res := &pb.BatchAnnotateImagesResponse{}
+
+	for _, r := range req.Requests {
+		sum := sha256.Sum256(r.Image.Content)
+		key := hex.EncodeToString(sum[:])
+		air := &pb.AnnotateImageResponse{}
+
+		for _, f := range r.Features {
+			switch f.Type {
+			case pb.Feature_LABEL_DETECTION:
+				for _, l := range v.labels[key] {
+					air.LabelAnnotations = append(air.LabelAnnotations, &pb.EntityAnnotation{Description: l, Score: 0.9})
+				}
+			case pb.Feature_TEXT_DETECTION:
+				v.detected = append(v.detected, key)
+
+				if text, ok := v.texts[key]; ok {
+					air.TextAnnotations = []*pb.EntityAnnotation{{Description: text}}
+				}
+			}
+		}
+
+		res.Responses = append(res.Responses, air)
+	}
+
+	return res, nil
+}
+
+// fakeSpotifyRepository is an in-memory Spotify with a catalog of
+// tracks, the genres of each artist and the playlists of the user,
+// which becomes unavailable after adding failAfter batches when set
+type fakeSpotifyRepository struct {
+	batches   int
+	catalog   []models.Track
+	failAfter int
+	genres    map[string][]string
+	playlists []*fakePlaylist
+	searches  []string
+}
+
+type fakePlaylist struct {
+	models.Playlist
+	tracks []models.Track
+}
+
+func (r *fakeSpotifyRepository) AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error) {
+	pl := r.playlist(playlist.ID)
+	if pl == nil {
+		return nil, fmt.Errorf("playlist %s not found", playlist.ID)
+	}
+
+	var batches []models.PlaylistBatch
+	for start := 0; start < len(tracks); start += 100 {
+		if r.failAfter > 0 && r.batches >= r.failAfter {
+			return batches, errors.New("service unavailable")
+		}
+
+		r.batches++
+
+		end := start + 100
+		if end > len(tracks) {
+			end = len(tracks)
+		}
+
+		batch := models.PlaylistBatch{}
+		for _, t := range tracks[start:end] {
+			batch.TrackIDs = append(batch.TrackIDs, t.ID)
+		}
+
+		pl.tracks = append(pl.tracks, tracks[start:end]...)
+		pl.SnapshotID = fmt.Sprintf("snapshot-%d", len(pl.tracks))
+		pl.TrackCount = len(pl.tracks)
+		batch.SnapshotID = pl.SnapshotID
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+func (r *fakeSpotifyRepository) CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	pl := &fakePlaylist{
+		Playlist: models.Playlist{
+			Collaborative: opts.Collaborative,
+			Description:   opts.Description,
+			ID:            fmt.Sprintf("playlist-%d", len(r.playlists)+1),
+			Name:          name,
+			Owner:         "songfinder",
+			Provider:      "spotify",
+			Public:        opts.Public,
+		},
+	}
+
+	r.playlists = append(r.playlists, pl)
+
+	return pl.Playlist, nil
+}
+
+func (r *fakeSpotifyRepository) FindPlaylist(name string) (*models.Playlist, error) {
+	for _, pl := range r.playlists {
+		if pl.Name == name {
+			found := pl.Playlist
+			return &found, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *fakeSpotifyRepository) GetGenres(track models.Track) ([]string, error) {
+	if len(track.Artists) == 0 {
+		return nil, nil
+	}
+
+	return r.genres[track.Artists[0]], nil
+}
+
+func (r *fakeSpotifyRepository) GetPlaylistTracks(playlist models.Playlist) ([]models.Track, error) {
+	if pl := r.playlist(playlist.ID); pl != nil {
+		return pl.tracks, nil
+	}
+
+	return nil, fmt.Errorf("playlist %s not found", playlist.ID)
+}
+
+func (r *fakeSpotifyRepository) GetTrack(id string) (*models.Track, error) {
+	r.searches = append(r.searches, "id:"+id)
+
+	for _, t := range r.catalog {
+		if t.ID == id {
+			return &t, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Search returns the tracks in the catalog with each word of the
+// search term in the artists or name of the track, ignoring case and
+// accents as Spotify does
+func (r *fakeSpotifyRepository) Search(searchTerm string, limit int) ([]models.Track, error) {
+	r.searches = append(r.searches, searchTerm)
+
+	var tracks []models.Track
+	for _, t := range r.catalog {
+		words := map[string]bool{}
+		for _, w := range fakeSearchWords(strings.Join(t.Artists, " ") + " " + t.Name) {
+			words[strings.Trim(w, "()")] = true
+		}
+
+		found := true
+		for _, w := range fakeSearchWords(searchTerm) {
+			found = found && words[w]
+		}
+
+		if found && len(tracks) < limit {
+			tracks = append(tracks, t)
+		}
+	}
+
+	return tracks, nil
+}
+
+func (r *fakeSpotifyRepository) SearchISRC(isrc string, limit int) ([]models.Track, error) {
+	r.searches = append(r.searches, "isrc:"+isrc)
+
+	for _, t := range r.catalog {
+		if t.ISRC == isrc {
+			return []models.Track{t}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func fakeSearchWords(text string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Fields(b.String())
+}
+
+func (r *fakeSpotifyRepository) playlist(id string) *fakePlaylist {
+	for _, pl := range r.playlists {
+		if pl.ID == id {
+			return pl
+		}
+	}
+
+	return nil
+}
+
+// writeVisionImage writes an image filled with the color and returns
+// the SHA-256 of its content the fake Vision backend identifies it by
+func writeVisionImage(t *testing.T, dir string, name string, fill color.Color) string {
+	s := writeClassifyImage(t, dir, name, 4, 4, func(x, y int) color.Color { return fill })
+
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBeginAndSyncPlaylistOffline(t *testing.T) {
+	dir := t.TempDir()
+
+	annotation, err := ioutil.ReadFile(filepath.Join(corpusPath, "spotify", "annotation.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		chat   = writeVisionImage(t, dir, "chat.png", color.White)
+		empty  = writeVisionImage(t, dir, "empty.png", color.Black)
+		photo  = writeVisionImage(t, dir, "photo.png", color.RGBA{R: 40, G: 120, B: 200, A: 255})
+		player = writeVisionImage(t, dir, "spotify.png", color.RGBA{R: 30, G: 215, B: 96, A: 255})
+		vision = &fakeVision{
+			labels: map[string][]string{
+				chat:   {"Font", "Screenshot"},
+				empty:  {"Screenshot"},
+				photo:  {"Sky", "Cloud"},
+				player: {"Font", "Screenshot", "Music"},
+			},
+			texts: map[string]string{
+				chat:   "Messages\nAre we still on for dinner tonight\nSee you at 7\n",
+				player: string(annotation),
+			},
+		}
+		spotify = &fakeSpotifyRepository{
+			catalog: []models.Track{
+				{Artists: []string{"Sonny Alven", "Cal"}, ID: "wasted", Name: "Wasted Youth (feat. Cal)", Provider: "spotify", URI: "spotify:track:wasted"},
+				{Artists: []string{"Sonny Alven"}, ID: "girls", Name: "Girls", Provider: "spotify", URI: "spotify:track:girls"},
+			},
+		}
+		ssr = repositories.NewScreenshotRepository(repositories.ScreenshotOptions{
+			ClientOptions: newFakeVision(t, vision),
+		})
+		mp  interfaces.IMusicProvider   = spotify
+		str interfaces.IStateRepository = &memoryStateRepository{}
+	)
+
+	state, err := services.NewScreenshotService(&ssr, &mp, &str, nil, nil).Begin(dir, models.DiscoveryOptions{
+		Classifier: services.ClassifierLabels,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]models.Status{
+		chat:   models.StatusUnparsed,
+		empty:  "",
+		photo:  models.StatusNotMusic,
+		player: models.StatusMatched,
+	}
+
+	for sha, status := range expected {
+		if s := state.Screenshots[sha]; s == nil || s.Status != status {
+			t.Errorf("expected %s to be recorded as %q: %+v", sha, status, s)
+		}
+	}
+
+	if len(vision.detected) != 3 {
+		t.Errorf("expected text to be detected in each screenshot classified as music: %v", vision.detected)
+	}
+
+	if len(spotify.searches) != 1 || spotify.searches[0] != "sonny alven wasted youth" {
+		t.Errorf("expected a single search for the song in the player: %v", spotify.searches)
+	}
+
+	ps := services.NewPlaylistService(&mp, &str)
+
+	for i := 0; i < 2; i++ {
+		if _, err := ps.EnsurePlaylist("Found Songs", state.Screenshots[player].Tracks(), models.PlaylistOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(spotify.playlists) != 1 || len(spotify.playlists[0].tracks) != 1 || spotify.playlists[0].tracks[0].ID != "wasted" {
+		t.Fatalf("expected the matched track to be added to the playlist once: %+v", spotify.playlists)
+	}
+
+	if state, err = services.NewScreenshotService(&ssr, &mp, &str, nil, nil).State(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sync := state.Playlists["spotify:playlist-1"]; sync == nil || !sync.Contains("wasted") {
+		t.Errorf("expected the sync to be recorded in state: %+v", state.Playlists)
+	}
+}
@@ -0,0 +1,385 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+type matchMetadataRepository map[string]*models.Recording
+
+func (r matchMetadataRepository) FindRecording(searchTerm string) (*models.Recording, error) {
+	return r[searchTerm], nil
+}
+
+func newMatchState(t *testing.T, text string) interfaces.IStateRepository {
+	var str interfaces.IStateRepository = &memoryStateRepository{}
+
+	if err := str.Save(models.State{
+		Screenshots: map[string]*models.Screenshot{
+			"a": {Path: "a.png", SHASum: "a", Text: text},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return str
+}
+
+func TestMatchWithMetadataSearchesByISRC(t *testing.T) {
+	var (
+		mdr interfaces.IMetadataRepository = matchMetadataRepository{
+			"the dig soul of the night": {
+				Artists: []string{"The Dig"},
+				ISRCs:   []string{"USQX91200001"},
+				Title:   "Soul of the Night",
+			},
+		}
+		mp = &fakeSpotifyRepository{
+			catalog: []models.Track{
+				{Artists: []string{"The Dig"}, ISRC: "USQX91200001", Name: "Soul of the Night", URI: "spotify:track:dig"},
+			},
+		}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Portland Radio Project\nThe Dig - Soul of the Night\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, &mdr, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := state.Screenshots["a"]
+	if s.Track.URI != "spotify:track:dig" || s.Recording == nil || s.Confidence != 1 {
+		t.Errorf("expected the track found by ISRC: %+v", s)
+	}
+
+	if len(mp.searches) != 1 || mp.searches[0] != "isrc:USQX91200001" {
+		t.Errorf("expected a single search by ISRC: %v", mp.searches)
+	}
+}
+
+func TestMatchWithMetadataSearchesCanonicalNames(t *testing.T) {
+	var (
+		mdr interfaces.IMetadataRepository = matchMetadataRepository{
+			"pnthnt ruda pge smallpools stumblin' home": {
+				Artists: []string{"Smallpools"},
+				Title:   "Stumblin' Home",
+			},
+		}
+		mp = &fakeSpotifyRepository{
+			catalog: []models.Track{
+				{Artists: []string{"Smallpools"}, Name: "Stumblin' Home", URI: "spotify:track:sp"},
+			},
+		}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Portland Radio Project\nPnthnt Ruda Pge Smallpools - Stumblin' Home\nSwipe up to open\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, &mdr, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := state.Screenshots["a"]; s.Track.URI != "spotify:track:sp" {
+		t.Errorf("expected the track found by the canonical names: %+v", s)
+	}
+}
+
+func TestMatchResolvesSpotifyLink(t *testing.T) {
+	for _, text := range []string{
+		"Check this out\nhttps://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc123\n",
+		"Notes\nspotify:track:4uLU6hMCjMI75M1A2tKUQC\n",
+	} {
+		var (
+			spr = &fakeSpotifyRepository{
+				catalog: []models.Track{
+					{Artists: []string{"Rick Astley"}, ID: "4uLU6hMCjMI75M1A2tKUQC", Name: "Never Gonna Give You Up", URI: "spotify:track:4uLU6hMCjMI75M1A2tKUQC"},
+				},
+			}
+			provider interfaces.IMusicProvider = spr
+			str                                = newMatchState(t, text)
+		)
+
+		state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s := state.Screenshots["a"]; s.Track.URI != "spotify:track:4uLU6hMCjMI75M1A2tKUQC" || s.Confidence != 1 {
+			t.Errorf("expected the track from the link: %+v", s)
+		}
+
+		if len(spr.searches) != 1 || spr.searches[0] != "id:4uLU6hMCjMI75M1A2tKUQC" {
+			t.Errorf("expected the track to be resolved by ID without searching: %v", spr.searches)
+		}
+	}
+}
+
+func TestMatchSearchesISRCInScreenshot(t *testing.T) {
+	var (
+		mp = &fakeSpotifyRepository{
+			catalog: []models.Track{
+				{Artists: []string{"SG Lewis"}, ISRC: "GBUM72000001", Name: "Chemicals", URI: "spotify:track:chem"},
+			},
+		}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Chemicals\nSG Lewis\nISRC: GB-UM7-20-00001\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := state.Screenshots["a"]; s.Track.URI != "spotify:track:chem" {
+		t.Errorf("expected the track found by ISRC: %+v", s)
+	}
+
+	if len(mp.searches) != 1 || mp.searches[0] != "isrc:GBUM72000001" {
+		t.Errorf("expected a single search by ISRC: %v", mp.searches)
+	}
+}
+
+func TestMatchSkipsSearchWithoutSong(t *testing.T) {
+	var (
+		mp                                 = &fakeSpotifyRepository{}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Messages\nAre we still on for dinner tonight\nSee you at 7\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := state.Screenshots["a"]
+	if s.Status != models.StatusUnparsed || s.Track.URI != "" || s.SearchReason == "" {
+		t.Errorf("expected the screenshot to be recorded as unparsed: %+v", s)
+	}
+
+	if len(mp.searches) != 0 {
+		t.Errorf("expected no search for text without a song: %v", mp.searches)
+	}
+}
+
+func TestMatchRecordsStatus(t *testing.T) {
+	var (
+		mp = &fakeSpotifyRepository{
+			catalog: []models.Track{
+				{Artists: []string{"The Dig"}, Name: "Soul of the Night", URI: "spotify:track:dig"},
+			},
+		}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Portland Radio Project\nThe Dig - Soul of the Night\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := state.Screenshots["a"]; s.Status != models.StatusMatched || s.SearchReason != "divider" {
+		t.Errorf("expected the screenshot to be recorded as matched by the divider: %+v", s)
+	}
+
+	// a song that is parsed but not found is unmatched
+	mp.catalog = nil
+	state, err = services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := state.Screenshots["a"]; s.Status != models.StatusUnmatched {
+		t.Errorf("expected the screenshot to be recorded as unmatched: %+v", s)
+	}
+}
+
+func TestMatchConfidenceIgnoresDiacritics(t *testing.T) {
+	var (
+		mp = &fakeSpotifyRepository{
+			catalog: []models.Track{
+				{Artists: []string{"Beyoncé"}, Name: "Halo", URI: "spotify:track:halo"},
+			},
+		}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Beyonce - Halo\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := state.Screenshots["a"]; s.Confidence != 1 {
+		t.Errorf("expected the accented artist to match the search term: %+v", s)
+	}
+}
+
+func TestMatchConfidenceUsesVersionHints(t *testing.T) {
+	tests := []struct {
+		text     string
+		track    models.Track
+		expected float64
+	}{
+		// the rest of the truncated title is not counted against the track
+		{
+			"Reverend Freakchild - Personal Jesus (On the...",
+			models.Track{Artists: []string{"Reverend Freakchild"}, Name: "Personal Jesus (On the Road Again)", URI: "spotify:track:rf"},
+			1,
+		},
+		// the remastered version matches the remastered title
+		{
+			"The Beatles - Here Comes the Sun - Remastered 2019",
+			models.Track{Artists: []string{"The Beatles"}, Name: "Here Comes The Sun - Remastered 2019", URI: "spotify:track:hc"},
+			1,
+		},
+		// the studio version is less likely to be the live song
+		{
+			"Nirvana - Lake of Fire (Live at MTV Unplugged)",
+			models.Track{Artists: []string{"Nirvana"}, Name: "Lake of Fire", URI: "spotify:track:lf"},
+			0.8,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			provider interfaces.IMusicProvider = &fakeSpotifyRepository{catalog: []models.Track{test.track}}
+			str                                = newMatchState(t, test.text)
+			svc                                = services.NewScreenshotService(nil, &provider, &str, nil, nil)
+		)
+
+		state, err := svc.Match(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s := state.Screenshots["a"]; s.Confidence != test.expected {
+			t.Errorf("expected confidence %.2f for %q: %.2f", test.expected, test.text, s.Confidence)
+		}
+	}
+}
+
+type matchArtistRepository []string
+
+func (r matchArtistRepository) Artists() ([]string, error) {
+	return r, nil
+}
+
+func TestMatchCorrectsMisreadArtists(t *testing.T) {
+	var (
+		provider interfaces.IMusicProvider    = &fakeSpotifyRepository{}
+		adr      interfaces.IArtistRepository = matchArtistRepository{"Reverend Freakchild"}
+		str      interfaces.IStateRepository  = &memoryStateRepository{}
+	)
+
+	if err := str.Save(models.State{
+		Screenshots: map[string]*models.Screenshot{
+			"a": {Path: "a.png", SHASum: "a", Text: "Smalpools - Stumblin' Home\n"},
+			"b": {Path: "b.png", SHASum: "b", Text: "Reverend Freakchlid - Personal Jesus\n"},
+			"c": {Path: "c.png", SHASum: "c", Text: "Sia - Chandelier\n"},
+			"d": {
+				Path:   "d.png",
+				SHASum: "d",
+				Text:   "Smallpools - Dreaming\n",
+				Track:  models.Track{Artists: []string{"Smallpools"}, Name: "Dreaming", URI: "spotify:track:dr"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, &adr).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"a": "smallpools stumblin' home",
+		"b": "reverend freakchild personal jesus",
+		"c": "sia chandelier",
+	}
+
+	for sha, term := range expected {
+		if s := state.Screenshots[sha]; s.SongSearchTerm != term {
+			t.Errorf("expected the corrected search term %q: %q", term, s.SongSearchTerm)
+		}
+	}
+}
+
+func TestMatchLeavesTitlesLikeArtists(t *testing.T) {
+	var (
+		provider interfaces.IMusicProvider   = &fakeSpotifyRepository{}
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+	)
+
+	if err := str.Save(models.State{
+		Screenshots: map[string]*models.Screenshot{
+			"a": {Path: "a.png", SHASum: "a", Text: "Joni Mitchell - Blue\n"},
+			"b": {Path: "b.png", SHASum: "b", Text: "The Killers - Must Be\n"},
+			"c": {Path: "c.png", SHASum: "c", Text: "Blurr - Song 2\n"},
+			"d": {
+				Path:   "d.png",
+				SHASum: "d",
+				Text:   "Blur - Parklife\n",
+				Track:  models.Track{Artists: []string{"Blur"}, Name: "Parklife", URI: "spotify:track:pl"},
+			},
+			"e": {
+				Path:   "e.png",
+				SHASum: "e",
+				Text:   "Muse - Uprising\n",
+				Track:  models.Track{Artists: []string{"Muse"}, Name: "Uprising", URI: "spotify:track:up"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"a": "joni mitchell blue",
+		"b": "the killers must be",
+		"c": "blur song 2",
+	}
+
+	for sha, term := range expected {
+		if s := state.Screenshots[sha]; s.SongSearchTerm != term {
+			t.Errorf("expected only the artist to be corrected in %q: %q", term, s.SongSearchTerm)
+		}
+	}
+}
+
+func TestMatchRecordsCutOffLayoutsAsUnparsed(t *testing.T) {
+	var (
+		provider interfaces.IMusicProvider   = &fakeSpotifyRepository{}
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+	)
+
+	if err := str.Save(models.State{
+		Screenshots: map[string]*models.Screenshot{
+			"a": {Path: "a.png", SHASum: "a", Text: "Listen on Spotify\n1:23\n2:34\n"},
+			"b": {Path: "b.png", SHASum: "b", Text: "Shared from Spotify\n12\n"},
+			"c": {Path: "c.png", SHASum: "c", Text: "Search\nPCM 44.1 kHz\n"},
+			"d": {Path: "d.png", SHASum: "d", Text: "My Shazams\nSong\n"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for sha, s := range state.Screenshots {
+		if s.Status != models.StatusUnparsed {
+			t.Errorf("expected %s to be recorded as unparsed: %+v", sha, s)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+// patterns of the version details appended to the title of a song,
+// each optionally preceded by the divider. Live versions are only
+// found in brackets or after the divider so titles such as "Live in
+// the Moment" are searched for
+var (
+	edited    = regexp.MustCompile(`(?i)\s*(?:[-–—]\s*)?[(\[]?\b(?:radio edit|radio version|single edit|single version|clean version|explicit version)\b[)\]]?`)
+	featured  = regexp.MustCompile(`(?i)\s*[(\[](?:feat\.?|ft\.?|featuring|with) ([^)\]]+)[)\]]`)
+	featuring = regexp.MustCompile(`(?i)\s+(?:feat\.|ft\.|featuring) (.+)$`)
+	live      = regexp.MustCompile(`(?i)(?:\s*[(\[]live\b[^)\]]*[)\]]|\s+[-–—]\s+live\b.*$)`)
+	remaster  = regexp.MustCompile(`(?i)\s*(?:[-–—]\s*)?[(\[]?\b(?:\d{4} )?(?:digital(?:ly)? )?remaster(?:ed)?(?: \d{4})?(?: version| edition)?\b[)\]]?`)
+	separator = regexp.MustCompile(`\s*(?:,|&| and | x )\s*`)
+	truncated = regexp.MustCompile(`\s*(?:[(\[][^)\]]*|\S*)(?:\.{3}|…)\s*$`)
+)
+
+// normalizeTitle separates the details of the version of the song
+// from the title, so they hint at the best matching track rather than
+// being searched for. Titles truncated by the player are cut back to
+// the last complete word and any divider left in the title is removed
+func normalizeTitle(title string) (string, models.TitleHints) {
+	hints := models.TitleHints{}
+
+	if truncated.MatchString(title) {
+		hints.Truncated = true
+		title = truncated.ReplaceAllString(title, "")
+	}
+
+	for _, m := range featured.FindAllStringSubmatch(title, -1) {
+		hints.Featured = append(hints.Featured, separator.Split(strings.TrimSpace(m[1]), -1)...)
+	}
+
+	title = featured.ReplaceAllString(title, "")
+
+	if m := featuring.FindStringSubmatch(title); m != nil {
+		hints.Featured = append(hints.Featured, separator.Split(strings.TrimSpace(m[1]), -1)...)
+		title = featuring.ReplaceAllString(title, "")
+	}
+
+	if live.MatchString(title) {
+		hints.Live = true
+		title = live.ReplaceAllString(title, "")
+	}
+
+	if remaster.MatchString(title) {
+		hints.Remastered = true
+		title = remaster.ReplaceAllString(title, "")
+	}
+
+	if edited.MatchString(title) {
+		hints.Edited = true
+		title = edited.ReplaceAllString(title, "")
+	}
+
+	return strings.Join(strings.Fields(div.ReplaceAllString(title, "$1 $3")), " "), hints
+}
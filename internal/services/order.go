@@ -0,0 +1,41 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+// Orders for the screenshots written to exports and playlists
+const (
+	OrderCaptured = "captured"
+	OrderPath     = "path"
+)
+
+// SortScreenshots orders the screenshots by path or chronologically
+// by the time each was captured, screenshots captured at the same
+// time are ordered by path
+func SortScreenshots(sss []*models.Screenshot, order string) {
+	sort.SliceStable(sss, func(i, j int) bool {
+		if order == OrderCaptured && !sss[i].Captured.Equal(sss[j].Captured) {
+			return sss[i].Captured.Before(sss[j].Captured)
+		}
+
+		return sss[i].Path < sss[j].Path
+	})
+}
+
+// CapturedBetween returns true when the screenshot was captured within
+// the range, a zero since or until leaves that end of the range open
+func CapturedBetween(s *models.Screenshot, since time.Time, until time.Time) bool {
+	if !since.IsZero() && s.Captured.Before(since) {
+		return false
+	}
+
+	if !until.IsZero() && s.Captured.After(until) {
+		return false
+	}
+
+	return true
+}
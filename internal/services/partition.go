@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// Partition rules for splitting the matched screenshots into
+// multiple playlists
+const (
+	PartitionGenre  = "genre"
+	PartitionMonth  = "month"
+	PartitionNone   = "none"
+	PartitionSource = "source"
+)
+
+const (
+	unknownDate   = "Unknown"
+	unknownGenre  = "Unknown"
+	unknownSource = "Other"
+)
+
+// sources are the apps a screenshot may be taken from, in the order
+// they are detected from the text of the screenshot
+var sources = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Shazam", regexp.MustCompile(`(?i)\bshazam`)},
+	{"SoundHound", regexp.MustCompile(`(?i)\bsoundhound\b`)},
+	{"Radio", regexp.MustCompile(`(?i)\b(radio|iheart|tunein|sirius ?xm|\d{2,3}\.\d ?fm)\b`)},
+	{"Spotify", regexp.MustCompile(`(?i)\bspotify\b`)},
+	{"Apple Music", regexp.MustCompile(`(?i)\bapple music\b`)},
+	{"YouTube", regexp.MustCompile(`(?i)\byoutube\b`)},
+}
+
+type partitionData struct {
+	Genre     string
+	Month     string
+	MonthName string
+	Source    string
+	Year      string
+}
+
+// Partition splits the screenshots into playlists by the rule, naming
+// each playlist with the name template. Screenshots are partitioned by
+// the month they were taken, the app they were taken from or the genre
+// of the artist of the matched track
+func (ps playlistService) Partition(rule string, name string, screenshots []*models.Screenshot) ([]models.PlaylistPartition, error) {
+	tmpl, err := template.New("playlist").Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var spr interfaces.ISpotifyRepository
+	switch rule {
+	case "", PartitionNone, PartitionMonth, PartitionSource:
+	case PartitionGenre:
+		var ok bool
+		if spr, ok = (*ps.musicProvider).(interfaces.ISpotifyRepository); !ok {
+			return nil, fmt.Errorf("partitioning by %s requires the Spotify provider", rule)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported partition rule %q", rule)
+	}
+
+	// casers keep state, so one is made for each partitioning
+	genreCase := cases.Title(language.Und)
+	partitions := []models.PlaylistPartition{}
+	index := map[string]int{}
+	for _, s := range screenshots {
+		data := partitionData{}
+
+		switch rule {
+		case PartitionGenre:
+			data.Genre = unknownGenre
+			genres, err := spr.GetGenres(s.Track)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(genres) > 0 {
+				data.Genre = genreCase.String(genres[0])
+			}
+		case PartitionMonth:
+			// screenshots taken at an unknown time are kept together
+			// rather than in the month of the zero time
+			taken := screenshotTime(s)
+			if taken.IsZero() {
+				data.Month = unknownDate
+				data.MonthName = unknownDate
+				data.Year = unknownDate
+				break
+			}
+
+			data.Month = taken.Format("01")
+			data.MonthName = taken.Format("January")
+			data.Year = taken.Format("2006")
+		case PartitionSource:
+			data.Source = detectSource(s.Text)
+		}
+
+		sb := &strings.Builder{}
+		if err := tmpl.Execute(sb, data); err != nil {
+			return nil, err
+		}
+
+		n := sb.String()
+		i, ok := index[n]
+		if !ok {
+			i = len(partitions)
+			index[n] = i
+			partitions = append(partitions, models.PlaylistPartition{Name: n})
+		}
+
+		partitions[i].Screenshots = append(partitions[i].Screenshots, s)
+	}
+
+	sort.SliceStable(partitions, func(i, j int) bool {
+		return partitions[i].Name < partitions[j].Name
+	})
+
+	log.Debug().
+		Str("rule", rule).
+		Int("playlists", len(partitions)).
+		Msg("partitioned screenshots into playlists")
+
+	return partitions, nil
+}
+
+// detectSource returns the app the screenshot was taken from based on
+// the text detected in it
+func detectSource(text string) string {
+	for _, s := range sources {
+		if s.pattern.MatchString(text) {
+			return s.name
+		}
+	}
+
+	return unknownSource
+}
+
+// screenshotTime returns the time the screenshot was captured, or the
+// time the file was last modified for state from a previous version,
+// or the zero time when neither is known
+func screenshotTime(s *models.Screenshot) time.Time {
+	if !s.Captured.IsZero() {
+		return s.Captured
+	}
+
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		log.Debug().Err(err).Str("path", s.Path).Msg("unable to read screenshot time")
+		return time.Time{}
+	}
+
+	return fi.ModTime()
+}
@@ -1,28 +1,127 @@
 package services
 
 import (
+	"fmt"
+
 	"github.com/brozeph/song-finder/internal/interfaces"
-	"github.com/zmb3/spotify"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
 )
 
 type playlistService struct {
-	spotifyRepository *interfaces.ISpotifyRepository
-	stateRepository   *interfaces.IStateRepository
+	musicProvider   *interfaces.IMusicProvider
+	stateRepository *interfaces.IStateRepository
 }
 
+// NewPlaylistService returns new instance of an IPlaylistService
 func NewPlaylistService(
-	spr *interfaces.ISpotifyRepository,
+	mp *interfaces.IMusicProvider,
 	str *interfaces.IStateRepository) interfaces.IPlaylistService {
 	return playlistService{
-		spotifyRepository: spr,
-		stateRepository:   str,
+		musicProvider:   mp,
+		stateRepository: str,
+	}
+}
+
+// EnsurePlaylist finds or creates the named playlist with the
+// music provider, applying the options when the playlist is created,
+// and adds the tracks to it. The batches added are recorded in state
+// so tracks added by a previous sync, including one that failed part
+// way through, are not added again
+func (ps playlistService) EnsurePlaylist(name string, tracks []models.Track, opts models.PlaylistOptions) (models.Playlist, error) {
+	mp := *ps.musicProvider
+	str := *ps.stateRepository
+
+	state, err := loadState(str)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	pl, err := ps.lookupPlaylist(name, opts)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	key := playlistKey(pl)
+	sync, ok := state.Playlists[key]
+	if !ok {
+		sync = &models.PlaylistSync{}
+		state.Playlists[key] = sync
+	}
+
+	sync.Playlist = pl
+
+	seen, err := ps.playlistTrackIDs(pl)
+	if err != nil {
+		return pl, err
+	}
+
+	pending := []models.Track{}
+	for _, t := range tracks {
+		if seen[t.ID] || sync.Contains(t.ID) {
+			continue
+		}
+
+		seen[t.ID] = true
+		pending = append(pending, t)
+	}
+
+	log.Debug().
+		Str("playlist", name).
+		Int("pending", len(pending)).
+		Int("tracks", len(tracks)).
+		Msg("adding tracks to playlist")
+
+	batches, err := mp.AddTracks(pl, pending)
+	sync.Batches = append(sync.Batches, batches...)
+
+	// save the batches added even when a later batch failed so the
+	// next sync resumes from where this one stopped
+	saveState(str, state)
+
+	return pl, err
+}
+
+func (ps playlistService) lookupPlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	mp := *ps.musicProvider
+
+	pl, err := mp.FindPlaylist(name)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	if pl != nil {
+		return *pl, nil
 	}
+
+	log.Debug().Str("playlist", name).Msg("creating playlist")
+
+	return mp.CreatePlaylist(name, opts)
 }
 
-func (ps playlistService) EnsurePlaylist(name string, tracks []spotify.SimpleTrack) error {
-	return nil
+// playlistTrackIDs returns the IDs of the tracks already in the playlist
+// when the music provider is able to list them so tracks added outside
+// of a sync recorded in state are not added again
+func (ps playlistService) playlistTrackIDs(pl models.Playlist) (map[string]bool, error) {
+	ids := map[string]bool{}
+
+	spr, ok := (*ps.musicProvider).(interfaces.ISpotifyRepository)
+	if !ok || pl.TrackCount == 0 {
+		return ids, nil
+	}
+
+	tracks, err := spr.GetPlaylistTracks(pl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tracks {
+		ids[t.ID] = true
+	}
+
+	return ids, nil
 }
 
-func (ps playlistService) lookupPlaylist(name string) spotify.SimplePlaylist {
-	return spotify.SimplePlaylist{}
+func playlistKey(pl models.Playlist) string {
+	return fmt.Sprintf("%s:%s", pl.Provider, pl.ID)
 }
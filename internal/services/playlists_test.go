@@ -0,0 +1,316 @@
+package services_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+func playlistTracks(count int) []models.Track {
+	tracks := make([]models.Track, 0, count)
+	for i := 0; i < count; i++ {
+		tracks = append(tracks, models.Track{ID: fmt.Sprintf("t%d", i)})
+	}
+
+	return tracks
+}
+
+// addedTracks returns the IDs of the tracks added to the playlists
+func addedTracks(spr *fakeSpotifyRepository) []string {
+	var added []string
+	for _, pl := range spr.playlists {
+		for _, t := range pl.tracks {
+			added = append(added, t.ID)
+		}
+	}
+
+	return added
+}
+
+func TestEnsurePlaylistResumesPartialSync(t *testing.T) {
+	var (
+		spr                                  = &fakeSpotifyRepository{failAfter: 2}
+		provider interfaces.IMusicProvider   = spr
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+		tracks                               = playlistTracks(250)
+	)
+
+	ps := services.NewPlaylistService(&provider, &str)
+
+	if _, err := ps.EnsurePlaylist("Screenshots", tracks, models.PlaylistOptions{}); err == nil {
+		t.Fatal("expected the third batch to fail")
+	}
+
+	if added := addedTracks(spr); len(added) != 200 {
+		t.Fatalf("expected two batches to be added: %d", len(added))
+	}
+
+	spr.failAfter = 0
+	if _, err := ps.EnsurePlaylist("Screenshots", tracks, models.PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if added := addedTracks(spr); len(added) != 250 {
+		t.Errorf("expected only the remaining tracks to be added: %d", len(added))
+	}
+
+	state := models.State{}
+	if err := str.Load(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	sync := state.Playlists["spotify:playlist-1"]
+	if sync == nil || len(sync.Batches) != 3 {
+		t.Fatalf("expected three batches recorded in state: %+v", sync)
+	}
+
+	for i, b := range sync.Batches {
+		if b.SnapshotID == "" {
+			t.Errorf("expected a snapshot ID for batch %d", i)
+		}
+	}
+}
+
+func TestEnsurePlaylistSkipsDuplicateTracks(t *testing.T) {
+	var (
+		spr                                  = &fakeSpotifyRepository{}
+		provider interfaces.IMusicProvider   = spr
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+		tracks                               = append(playlistTracks(3), playlistTracks(2)...)
+	)
+
+	if _, err := services.NewPlaylistService(&provider, &str).EnsurePlaylist("Screenshots", tracks, models.PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if added := addedTracks(spr); len(added) != 3 {
+		t.Errorf("expected duplicate tracks to be added once: %v", added)
+	}
+}
+
+func TestEnsurePlaylistSkipsTracksInSpotifyPlaylist(t *testing.T) {
+	var (
+		spr = &fakeSpotifyRepository{
+			playlists: []*fakePlaylist{{
+				Playlist: models.Playlist{ID: "p1", Name: "Screenshots", Provider: "spotify", TrackCount: 2},
+				tracks:   playlistTracks(2),
+			}},
+		}
+		provider interfaces.IMusicProvider   = spr
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+	)
+
+	pl, err := services.NewPlaylistService(&provider, &str).EnsurePlaylist("Screenshots", playlistTracks(5), models.PlaylistOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pl.ID != "p1" {
+		t.Errorf("expected the existing playlist: %+v", pl)
+	}
+
+	if added := addedTracks(spr)[2:]; len(added) != 3 || added[0] != "t2" {
+		t.Errorf("expected only tracks missing from the playlist to be added: %v", added)
+	}
+}
+
+func writeScreenshotImage(t *testing.T, dir string, name string, modTime time.Time) *models.Screenshot {
+	img := image.NewRGBA(image.Rect(0, 0, 1170, 2532))
+	for y := 0; y < 2532; y++ {
+		for x := 0; x < 1170; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.Screenshot{Path: path}
+}
+
+func TestPlaylistCoverAndDescription(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	screenshots := []*models.Screenshot{
+		writeScreenshotImage(t, dir, "a.png", time.Date(2021, 1, 2, 12, 0, 0, 0, time.Local)),
+		writeScreenshotImage(t, dir, "b.png", time.Date(2021, 3, 4, 12, 0, 0, 0, time.Local)),
+		{Path: filepath.Join(dir, "missing.png")},
+	}
+
+	var (
+		provider interfaces.IMusicProvider   = &fakeSpotifyRepository{}
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+		ps                                   = services.NewPlaylistService(&provider, &str)
+	)
+
+	cover, err := ps.Cover(screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if base64.StdEncoding.EncodedLen(len(cover)) > 256*1024 {
+		t.Errorf("expected the encoded cover to be no larger than 256KB: %d", len(cover))
+	}
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(cover))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Width != cfg.Height {
+		t.Errorf("expected a square cover image: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	description, err := ps.Description(services.DefaultPlaylistDescription, screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "Songs found in 3 screenshots from 2021-01-02 to 2021-03-04"; description != expected {
+		t.Errorf("expected %q: %q", expected, description)
+	}
+}
+
+func partitionNames(partitions []models.PlaylistPartition) map[string]int {
+	names := map[string]int{}
+	for _, p := range partitions {
+		names[p.Name] = len(p.Screenshots)
+	}
+
+	return names
+}
+
+func TestPartitionByMonthAndSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "song-finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	screenshots := []*models.Screenshot{
+		writeScreenshotImage(t, dir, "a.png", time.Date(2021, 1, 2, 12, 0, 0, 0, time.Local)),
+		writeScreenshotImage(t, dir, "b.png", time.Date(2021, 1, 20, 12, 0, 0, 0, time.Local)),
+		writeScreenshotImage(t, dir, "c.png", time.Date(2021, 3, 4, 12, 0, 0, 0, time.Local)),
+	}
+	screenshots[0].Text = "Shazam\nChemicals\nSG Lewis"
+	screenshots[1].Text = "KEXP 90.3 FM\nNow playing"
+
+	// wrapping Spotify hides the methods partitioning by genre needs
+	var (
+		provider interfaces.IMusicProvider   = struct{ interfaces.IMusicProvider }{&fakeSpotifyRepository{}}
+		str      interfaces.IStateRepository = &memoryStateRepository{}
+		ps                                   = services.NewPlaylistService(&provider, &str)
+	)
+
+	partitions, err := ps.Partition(services.PartitionMonth, "Found {{.Year}}-{{.Month}}", screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := partitionNames(partitions); len(names) != 2 || names["Found 2021-01"] != 2 || names["Found 2021-03"] != 1 {
+		t.Errorf("unexpected partitions by month: %v", names)
+	}
+
+	partitions, err = ps.Partition(services.PartitionSource, "{{.Source}} finds", screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := partitionNames(partitions); names["Shazam finds"] != 1 || names["Radio finds"] != 1 || names["Other finds"] != 1 {
+		t.Errorf("unexpected partitions by source: %v", names)
+	}
+
+	partitions, err = ps.Partition(services.PartitionNone, "Screenshots", screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := partitionNames(partitions); len(names) != 1 || names["Screenshots"] != 3 {
+		t.Errorf("expected a single playlist: %v", names)
+	}
+
+	if _, err := ps.Partition(services.PartitionGenre, "{{.Genre}}", screenshots); err == nil {
+		t.Error("expected an error partitioning by genre without Spotify")
+	}
+}
+
+func TestPartitionByMonthKeepsUnknownTimes(t *testing.T) {
+	var (
+		provider    interfaces.IMusicProvider   = &fakeSpotifyRepository{}
+		str         interfaces.IStateRepository = &memoryStateRepository{}
+		screenshots                             = []*models.Screenshot{
+			{Captured: time.Date(2021, 1, 2, 12, 0, 0, 0, time.Local), Path: "a.png"},
+			{Path: "missing-b.png"},
+			{Path: "missing-c.png"},
+		}
+	)
+
+	partitions, err := services.NewPlaylistService(&provider, &str).Partition(services.PartitionMonth, "Found {{.Year}} {{.MonthName}}", screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := partitionNames(partitions); len(names) != 2 || names["Found 2021 January"] != 1 || names["Found Unknown Unknown"] != 2 {
+		t.Errorf("expected screenshots without a capture time to be partitioned together: %v", names)
+	}
+}
+
+func TestPartitionByGenre(t *testing.T) {
+	var (
+		spr = &fakeSpotifyRepository{
+			genres: map[string][]string{
+				"Beyoncé":    {"r&b", "pop"},
+				"BTS":        {"k-pop"},
+				"SG Lewis":   {"indie pop", "uk dance"},
+				"Smallpools": {"indie pop"},
+			},
+		}
+		provider    interfaces.IMusicProvider   = spr
+		str         interfaces.IStateRepository = &memoryStateRepository{}
+		screenshots                             = []*models.Screenshot{
+			{Track: models.Track{Artists: []string{"SG Lewis"}, ID: "a"}},
+			{Track: models.Track{Artists: []string{"Smallpools"}, ID: "b"}},
+			{Track: models.Track{Artists: []string{"The Dig"}, ID: "c"}},
+			{Track: models.Track{Artists: []string{"Beyoncé"}, ID: "d"}},
+			{Track: models.Track{Artists: []string{"BTS"}, ID: "e"}},
+		}
+	)
+
+	partitions, err := services.NewPlaylistService(&provider, &str).Partition(services.PartitionGenre, "Found {{.Genre}}", screenshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := partitionNames(partitions); names["Found Indie Pop"] != 2 || names["Found Unknown"] != 1 || names["Found R&B"] != 1 || names["Found K-Pop"] != 1 {
+		t.Errorf("unexpected partitions by genre: %v", names)
+	}
+}
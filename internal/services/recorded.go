@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+var errRecordedPlaylist = errors.New("playlists are not supported by recorded responses")
+
+type recordedMusicProvider struct {
+	responses models.RecordedResponses
+}
+
+type recordingMusicProvider struct {
+	musicProvider *interfaces.IMusicProvider
+	responses     *models.RecordedResponses
+}
+
+// newRecordedMusicProvider returns an instance of IMusicProvider that
+// replays the recorded responses, finding no tracks for search terms
+// and ISRCs that weren't recorded
+func newRecordedMusicProvider(responses models.RecordedResponses) interfaces.IMusicProvider {
+	return &recordedMusicProvider{
+		responses: responses,
+	}
+}
+
+// newRecordingMusicProvider returns an instance of IMusicProvider that
+// searches the music provider and records the tracks found for each
+// search term and ISRC in the responses
+func newRecordingMusicProvider(mp *interfaces.IMusicProvider, responses *models.RecordedResponses) interfaces.IMusicProvider {
+	return &recordingMusicProvider{
+		musicProvider: mp,
+		responses:     responses,
+	}
+}
+
+// AddTracks is not supported by recorded responses
+func (r *recordedMusicProvider) AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error) {
+	return nil, errRecordedPlaylist
+}
+
+// CreatePlaylist is not supported by recorded responses
+func (r *recordedMusicProvider) CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	return models.Playlist{}, errRecordedPlaylist
+}
+
+// FindPlaylist is not supported by recorded responses
+func (r *recordedMusicProvider) FindPlaylist(name string) (*models.Playlist, error) {
+	return nil, errRecordedPlaylist
+}
+
+// GetTrack finds no track as tracks are not recorded by ID
+func (r *recordedMusicProvider) GetTrack(id string) (*models.Track, error) {
+	return nil, nil
+}
+
+// Search returns the tracks recorded for the search term
+func (r *recordedMusicProvider) Search(searchTerm string, limit int) ([]models.Track, error) {
+	return recordedTracks(r.responses.Search[searchTerm], limit), nil
+}
+
+// SearchISRC returns the tracks recorded for the ISRC
+func (r *recordedMusicProvider) SearchISRC(isrc string, limit int) ([]models.Track, error) {
+	return recordedTracks(r.responses.ISRC[isrc], limit), nil
+}
+
+// AddTracks is not supported while recording responses
+func (r *recordingMusicProvider) AddTracks(playlist models.Playlist, tracks []models.Track) ([]models.PlaylistBatch, error) {
+	return nil, errRecordedPlaylist
+}
+
+// CreatePlaylist is not supported while recording responses
+func (r *recordingMusicProvider) CreatePlaylist(name string, opts models.PlaylistOptions) (models.Playlist, error) {
+	return models.Playlist{}, errRecordedPlaylist
+}
+
+// FindPlaylist is not supported while recording responses
+func (r *recordingMusicProvider) FindPlaylist(name string) (*models.Playlist, error) {
+	return nil, errRecordedPlaylist
+}
+
+// GetTrack retrieves the track from the music provider without
+// recording it
+func (r *recordingMusicProvider) GetTrack(id string) (*models.Track, error) {
+	return (*r.musicProvider).GetTrack(id)
+}
+
+// Search searches the music provider and records the tracks found
+func (r *recordingMusicProvider) Search(searchTerm string, limit int) ([]models.Track, error) {
+	tracks, err := (*r.musicProvider).Search(searchTerm, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.responses.Search == nil {
+		r.responses.Search = map[string][]models.Track{}
+	}
+
+	r.responses.Search[searchTerm] = tracks
+
+	return tracks, nil
+}
+
+// SearchISRC searches the music provider by ISRC and records the
+// tracks found
+func (r *recordingMusicProvider) SearchISRC(isrc string, limit int) ([]models.Track, error) {
+	tracks, err := (*r.musicProvider).SearchISRC(isrc, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.responses.ISRC == nil {
+		r.responses.ISRC = map[string][]models.Track{}
+	}
+
+	r.responses.ISRC[isrc] = tracks
+
+	return tracks, nil
+}
+
+func recordedTracks(tracks []models.Track, limit int) []models.Track {
+	if limit > 0 && len(tracks) > limit {
+		return tracks[:limit]
+	}
+
+	return tracks
+}
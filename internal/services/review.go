@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+)
+
+const candidateLimit = 5
+
+type reviewService struct {
+	musicProvider   *interfaces.IMusicProvider
+	stateRepository *interfaces.IStateRepository
+}
+
+// NewReviewService returns new instance of an IReviewService
+func NewReviewService(
+	mp *interfaces.IMusicProvider,
+	str *interfaces.IStateRepository) interfaces.IReviewService {
+
+	return &reviewService{
+		musicProvider:   mp,
+		stateRepository: str,
+	}
+}
+
+// Candidates returns the top tracks from the music provider
+// for the search term
+func (rs *reviewService) Candidates(searchTerm string) ([]models.Track, error) {
+	mp := *rs.musicProvider
+
+	return mp.Search(searchTerm, candidateLimit)
+}
+
+// Track returns the track with the ID from the music provider or
+// nil when the track does not exist
+func (rs *reviewService) Track(id string) (*models.Track, error) {
+	mp := *rs.musicProvider
+
+	return mp.GetTrack(id)
+}
+
+// Pending returns the screenshots, ordered by path, that have
+// not been reviewed and either have no match or a match with
+// a confidence below the threshold
+func (rs *reviewService) Pending(threshold float64) ([]*models.Screenshot, error) {
+	state, err := loadState(*rs.stateRepository)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*models.Screenshot
+
+	for _, s := range state.Screenshots {
+		if s.Text == "" || s.Override != nil {
+			continue
+		}
+
+		if s.Track.URI == "" || s.Confidence < threshold {
+			pending = append(pending, s)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Path < pending[j].Path
+	})
+
+	return pending, nil
+}
+
+// Resolve records the track chosen for the screenshot as
+// a manual override
+func (rs *reviewService) Resolve(shaSum string, searchTerm string, track models.Track) error {
+	return rs.override(shaSum, func(s *models.Screenshot) {
+		s.Confidence = 1
+		s.Override = &models.Override{Reviewed: time.Now()}
+		s.SongSearchTerm = searchTerm
+		s.Status = models.StatusManuallyResolved
+		s.Track = track
+	})
+}
+
+// Skip records that the screenshot is not a song as a
+// manual override
+func (rs *reviewService) Skip(shaSum string) error {
+	return rs.override(shaSum, func(s *models.Screenshot) {
+		s.Confidence = 0
+		s.Override = &models.Override{
+			NotASong: true,
+			Reviewed: time.Now(),
+		}
+		s.Status = models.StatusNotMusic
+		s.Track = models.Track{}
+	})
+}
+
+func (rs *reviewService) override(shaSum string, apply func(s *models.Screenshot)) error {
+	str := *rs.stateRepository
+
+	state, err := loadState(str)
+	if err != nil {
+		return err
+	}
+
+	s, exists := state.Screenshots[shaSum]
+	if !exists {
+		return fmt.Errorf("screenshot %s not found in state", shaSum)
+	}
+
+	apply(s)
+
+	return str.Save(state)
+}
@@ -0,0 +1,117 @@
+package services_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+type memoryStateRepository struct {
+	data []byte
+}
+
+func (r *memoryStateRepository) Load(v interface{}) error {
+	if r.data == nil {
+		return os.ErrNotExist
+	}
+
+	return json.Unmarshal(r.data, v)
+}
+
+func (r *memoryStateRepository) Save(v interface{}) error {
+	b, err := json.Marshal(v)
+	r.data = b
+
+	return err
+}
+
+func newReviewState(t *testing.T) interfaces.IStateRepository {
+	var str interfaces.IStateRepository = &memoryStateRepository{}
+
+	state := models.State{
+		Screenshots: map[string]*models.Screenshot{
+			"a": {Path: "a.png", SHASum: "a", Text: "unmatched"},
+			"b": {
+				Confidence: 0.25,
+				Path:       "b.png",
+				SHASum:     "b",
+				Track:      models.Track{URI: "spotify:track:b"},
+				Text:       "low confidence",
+			},
+			"c": {
+				Confidence: 1,
+				Path:       "c.png",
+				SHASum:     "c",
+				Track:      models.Track{URI: "spotify:track:c"},
+				Text:       "matched",
+			},
+			"d": {Path: "d.png", SHASum: "d"},
+		},
+	}
+
+	if err := str.Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	return str
+}
+
+func TestReviewPending(t *testing.T) {
+	str := newReviewState(t)
+	rs := services.NewReviewService(nil, &str)
+
+	pending, err := rs.Pending(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 2 || pending[0].SHASum != "a" || pending[1].SHASum != "b" {
+		t.Errorf("expected screenshots a and b to be pending: %v", pending)
+	}
+}
+
+func TestReviewResolveAndSkip(t *testing.T) {
+	str := newReviewState(t)
+	rs := services.NewReviewService(nil, &str)
+	track := models.Track{Name: "Song", URI: "spotify:track:a"}
+
+	if err := rs.Resolve("a", "artist song", track); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rs.Skip("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := rs.Pending(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 0 {
+		t.Errorf("expected no screenshots to be pending: %v", pending)
+	}
+
+	state := models.State{}
+	if err := str.Load(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	a := state.Screenshots["a"]
+	if a.Override == nil || a.Track.URI != track.URI || a.SongSearchTerm != "artist song" {
+		t.Errorf("expected screenshot a to be resolved: %+v", a)
+	}
+
+	b := state.Screenshots["b"]
+	if b.Override == nil || !b.Override.NotASong || b.Track.URI != "" {
+		t.Errorf("expected screenshot b to be marked as not a song: %+v", b)
+	}
+
+	if err := rs.Skip("missing"); err == nil {
+		t.Error("expected an error skipping a screenshot not in state")
+	}
+}
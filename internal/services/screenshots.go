@@ -13,122 +13,248 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/superhawk610/bar"
 	"github.com/ttacon/chalk"
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
-	close  = regexp.MustCompile(`[\w\d]?\)`)
+	close  = regexp.MustCompile(`[\p{L}\p{N}]?\)`)
 	cr     = regexp.MustCompile(`\n`)
-	div    = regexp.MustCompile(`(\b|\.)( - )(\b)`)
+	div    = regexp.MustCompile(`([\p{L}\p{N}_.)])( [-–—] )([\p{L}\p{N}_])`)
 	dot    = regexp.MustCompile(`\s?•\s`)
-	feat   = regexp.MustCompile(`(?i)\(feat\. [.\s\d\w]*\)`)
+	icn    = regexp.MustCompile(`(?:\x{3000}|\s{2,})\S\s*$`)
+	isrc   = regexp.MustCompile(`(?i)\bISRC:?\s*([A-Z]{2}-?[A-Z0-9]{3}-?[0-9]{2}-?[0-9]{5})\b`)
 	jnk    = regexp.MustCompile(`([•.]\s?){3}`)
-	num    = regexp.MustCompile(`^[\d\W]*$`)
-	open   = regexp.MustCompile(`\([\w\d]?`)
+	num    = regexp.MustCompile(`^[^\p{L}]*$`)
+	open   = regexp.MustCompile(`\([\p{L}\p{N}]?`)
 	pcm    = regexp.MustCompile(`(?i)pcm [0-9]+\.[0-9]+\ khz`)
 	pndra  = regexp.MustCompile(`(?i)\bpandora\b`)
-	ply    = regexp.MustCompile(`(?i)^playing from`)
+	ply    = regexp.MustCompile(`(?i)^(playing from|reproduciendo desde|wird abgespielt|lecture depuis|en lecture depuis|in riproduzione da|tocando de|reproduzindo de|再生中)`)
 	prp    = regexp.MustCompile(`(?i)po(r)?(n)?tland radi[so] pr(o)?[jy]e[ac]t`)
-	rm     = regexp.MustCompile(`(?i)(\w* \S*)?room( \+ [0-9])?$`)
-	shzm   = regexp.MustCompile(`(?i)[0-9,]*\s*shazams`)
+	rm     = regexp.MustCompile(`(?i)([\p{L}\p{N}_]* \S*)?room( \+ [0-9])?$`)
+	shzm   = regexp.MustCompile(`(?i)[0-9,.\x{00a0}\x{202f}]*\s*shazams`)
 	sns    = regexp.MustCompile(`(?i)sonos`)
-	snsrad = regexp.MustCompile(`(?i)on sonos radio`)
+	snsrad = regexp.MustCompile(`(?i)(on|en|auf|sur|su) sonos radio`)
 	sp     = regexp.MustCompile(` `)
 	sptfy  = regexp.MustCompile(`(?i)[\n\s]spotify\b`)
-	srch   = regexp.MustCompile(`(?i)(^|\n)search($|\n)`)
-	swpup  = regexp.MustCompile(`(?i)swipe up to [oó]pen`)
-	wd     = regexp.MustCompile(`\w+`)
+	srch   = regexp.MustCompile(`(?i)(^|\n)(search|buscar|suche|rechercher|cerca)($|\n)`)
+	sptrk  = regexp.MustCompile(`(?:open\.spotify\.com/(?:intl-[a-z]+/)?track/|spotify:track:)([0-9A-Za-z]{22})`)
+	swpup  = regexp.MustCompile(`(?i)(swipe up to [oó]pen|desliza hacia arriba para abrir|zum [öo]ffnen nach oben wischen|balayez vers le haut pour ouvrir|scorri verso l'alto per aprire)`)
+	wd     = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+// confidence that the annotation is a song by how it was parsed
+const (
+	confidenceDivider   = 0.75
+	confidenceLayout    = 0.9
+	confidenceRemaining = 0.5
 )
 
 type screenshotService struct {
+	artistRepository     *interfaces.IArtistRepository
+	metadataRepository   *interfaces.IMetadataRepository
+	musicProvider        *interfaces.IMusicProvider
 	screenshotRepository *interfaces.IScreenshotRepository
-	spotifyRepository    *interfaces.ISpotifyRepository
 	stateRepository      *interfaces.IStateRepository
 }
 
-// NewScreenshotService returns new instance of an IScreenshotService
+// NewScreenshotService returns new instance of an IScreenshotService,
+// the metadata repository is optional and verifies songs before
+// searching the music provider when supplied. The artist repository
+// is also optional and adds to the artists of matched tracks that
+// songs are corrected against
 func NewScreenshotService(
 	ssr *interfaces.IScreenshotRepository,
-	spr *interfaces.ISpotifyRepository,
-	str *interfaces.IStateRepository) interfaces.IScreenshotService {
+	mp *interfaces.IMusicProvider,
+	str *interfaces.IStateRepository,
+	mdr *interfaces.IMetadataRepository,
+	adr *interfaces.IArtistRepository) interfaces.IScreenshotService {
 
 	return &screenshotService{
+		artistRepository:     adr,
+		metadataRepository:   mdr,
+		musicProvider:        mp,
 		screenshotRepository: ssr,
-		spotifyRepository:    spr,
 		stateRepository:      str,
 	}
 }
 
 // Begin starts processing the supplied path
-// and reading image files
-func (ss *screenshotService) Begin(path string) (models.State, error) {
-	var (
-		ssr   = *ss.screenshotRepository
-		spr   = *ss.spotifyRepository
-		state = &models.State{}
-		str   = *ss.stateRepository
-	)
+// by scanning for image files and then matching
+// the detected text with the music provider
+func (ss *screenshotService) Begin(path string, opts models.DiscoveryOptions) (models.State, error) {
+	if state, err := ss.Scan(path, false, opts); err != nil {
+		return state, err
+	}
 
-	if err := str.Load(state); err != nil {
-		if !os.IsNotExist(err) {
-			log.Fatal().Stack().Err(err).Msg("unable to load state")
+	return ss.Match(false)
+}
+
+// Match parses the detected text of each scanned screenshot
+// in state and searches the music provider for the song
+func (ss *screenshotService) Match(force bool) (models.State, error) {
+	state, err := loadState(*ss.stateRepository)
+	if err != nil {
+		return models.State{}, err
+	}
+
+	dict, err := ss.artistDictionary(state)
+	if err != nil {
+		return *state, err
+	}
+
+	b := newProgressBar("matching", len(state.Screenshots))
+
+	for _, s := range state.Screenshots {
+		b.Tick()
+
+		// nothing to match when text hasn't been detected
+		if s.Text == "" {
+			continue
 		}
 
-		state = &models.State{
-			Screenshots:     map[string]*models.Screenshot{},
-			SoftwareVersion: softwareVersion,
+		// manual overrides from review take precedence
+		if s.Override != nil {
+			continue
+		}
+
+		// pass on searching again when the software versions match
+		if !force && !s.LastSearched.IsZero() && state.SoftwareVersion == softwareVersion {
+			continue
+		}
+
+		if err := ss.matchScreenshot(s, dict); err != nil {
+			saveState(*ss.stateRepository, state)
+			return *state, err
+		}
+	}
+
+	// mark the progress bar as complete
+	b.Done()
+
+	state.Completed = time.Now()
+	state.SoftwareVersion = softwareVersion
+	saveState(*ss.stateRepository, state)
+
+	return *state, nil
+}
+
+// matchScreenshot parses the songs from the detected text of the
+// screenshot, correcting them against the dictionary of artists, and
+// searches the music provider for each of them
+func (ss *screenshotService) matchScreenshot(s *models.Screenshot, dict *spellDictionary) error {
+	terms := ss.parseSongs(s)
+	for i := range terms {
+		terms[i].Term = dict.correctArtist(terms[i].Term)
+	}
+
+	if len(terms) > 1 {
+		return ss.matchSongs(s, terms)
+	}
+
+	st := terms[0]
+	s.SearchReason = st.Reason
+	s.Songs = nil
+
+	// chat messages, notes and the like are not searched unless
+	// they link to a track, which would otherwise match any track
+	if !st.Parsed() && spotifyTrackID(s.Text) == "" && isrcCode(s.Text) == "" {
+		log.Debug().Str("path", s.Path).Msg("no song found in screenshot text")
+
+		s.Confidence = 0
+		s.SongSearchTerm = st.Term
+		s.Status = models.StatusUnparsed
+		s.Track = models.Track{}
+		return nil
+	}
+
+	s.Status = models.StatusParsed
+
+	track, query, err := ss.search(s, st.Term)
+	if err != nil {
+		return err
+	}
+
+	s.Confidence = matchConfidence(query, track, st.Hints)
+	s.LastSearched = time.Now()
+	s.SongSearchTerm = st.Term
+	s.Status = models.StatusUnmatched
+	s.Track = track
+
+	if track.URI != "" {
+		s.Status = models.StatusMatched
+	}
+
+	return nil
+}
+
+// parseSongs returns the search terms of the songs in the detected text
+// of the screenshot, which has no song when the parser fails on it so
+// the screenshots that remain are still matched
+func (ss *screenshotService) parseSongs(s *models.Screenshot) (terms []models.SearchTerm) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("path", s.Path).Interface("panic", r).Msg("unable to parse screenshot text")
+			terms = []models.SearchTerm{{Reason: "unable to parse text"}}
 		}
+	}()
+
+	return ss.SearchTerms(s.Text)
+}
+
+// Scan finds image files in the supplied path and
+// detects the text within each of them
+func (ss *screenshotService) Scan(path string, force bool, opts models.DiscoveryOptions) (models.State, error) {
+	ssr := *ss.screenshotRepository
+
+	state, err := loadState(*ss.stateRepository)
+	if err != nil {
+		return models.State{}, err
 	}
 
 	// load screenshot paths from screenshotRepository
-	screenShots, err := ssr.FindInPath(path)
+	screenShots, err := ssr.FindInPath(path, opts)
 	if err != nil {
 		return *state, err
 	}
 
-	b := bar.NewWithOpts(
-		bar.WithDimensions(len(screenShots), len(screenShots)),
-		bar.WithFormat(
-			fmt.Sprintf(
-				" %sprocessing...%s :percent :bar %s:eta%s     ",
-				chalk.Blue,
-				chalk.Reset,
-				chalk.Green,
-				chalk.Reset,
-			),
-		),
-	)
+	b := newProgressBar("scanning", len(screenShots))
 
 	for _, s := range screenShots {
 		b.Tick()
 
 		// check if screenshot is in the state already
 		if found, exists := state.Screenshots[s.SHASum]; exists {
-			// pass on processing the screenshot if the software versions match
-			if state.SoftwareVersion == softwareVersion {
+			// the file may have moved since it was last scanned and
+			// state from a previous version has no capture details
+			found.Captured = s.Captured
+			found.Device = s.Device
+			found.Location = s.Location
+			found.Path = s.Path
+
+			// pass when text was already detected or the
+			// track has already been matched
+			if !force && (found.Text != "" || found.Track.URI != "") {
 				continue
 			}
 
-			// pass when Spotify track has already been matched
-			if &found.SpotifyTrack != nil {
-				continue
-			}
+			s = found
 		}
 
-		text, err := ssr.DetectText(s.Path)
-		if err != nil {
-			return *state, err
+		if !ss.isCandidate(s, force, opts.Classifier) {
+			s.Status = models.StatusNotMusic
+			state.Screenshots[s.SHASum] = s
+			continue
 		}
 
-		song := ss.SearchTerm(text)
-		track, err := spr.Search(song)
-
+		text, err := ssr.DetectText(s.Path)
 		if err != nil {
+			saveState(*ss.stateRepository, state)
 			return *state, err
 		}
 
-		s.LastSearched = time.Now()
-		s.SongSearchTerm = song
-		s.SpotifyTrack = track
+		s.LastDetected = time.Now()
+		s.Status = ""
+		s.Text = text
 
 		state.Screenshots[s.SHASum] = s
 	}
@@ -136,23 +262,180 @@ func (ss *screenshotService) Begin(path string) (models.State, error) {
 	// mark the progress bar as complete
 	b.Done()
 
-	// save the state off for subsequent use
-	if err := str.Save(state); err != nil {
-		log.Error().Stack().Err(err).Msg("unable to save the state")
+	saveState(*ss.stateRepository, state)
+
+	return *state, nil
+}
+
+// artistDictionary returns the dictionary of the artists of the tracks
+// matched in state and those supplied with the artist repository
+func (ss *screenshotService) artistDictionary(state *models.State) (*spellDictionary, error) {
+	var artists []string
+
+	for _, s := range state.Screenshots {
+		for _, t := range s.Tracks() {
+			artists = append(artists, t.Artists...)
+		}
+
+		if s.Recording != nil {
+			artists = append(artists, s.Recording.Artists...)
+		}
+	}
+
+	if ss.artistRepository != nil {
+		known, err := (*ss.artistRepository).Artists()
+		if err != nil {
+			return nil, err
+		}
+
+		artists = append(artists, known...)
+	}
+
+	log.Debug().Int("artists", len(artists)).Msg("loaded artists for correcting songs")
+
+	return newSpellDictionary(artists), nil
+}
+
+// isCandidate classifies the screenshot, unless it was classified
+// by a previous scan, and returns true when it is probably a music
+// screenshot that text should be detected in
+func (ss *screenshotService) isCandidate(s *models.Screenshot, force bool, classifier string) bool {
+	if classifier == ClassifierNone {
+		return true
+	}
+
+	if classifier == "" {
+		classifier = ClassifierLocal
+	}
+
+	if force || s.Classification == nil || s.Classification.Classifier != classifier {
+		c, err := ss.classify(s, classifier)
+		if err != nil {
+			// detect text when the screenshot can not be classified
+			log.Warn().Err(err).Str("path", s.Path).Msg("unable to classify screenshot")
+			return true
+		}
+
+		s.Classification = &c
+	}
+
+	log.Debug().
+		Str("path", s.Path).
+		Bool("music", s.Classification.Music).
+		Str("reason", s.Classification.Reason).
+		Msg("classified screenshot")
+
+	return s.Classification.Music
+}
+
+// search finds the track for the song with the music provider and
+// returns the search term used. Spotify links and ISRCs found in the
+// screenshot resolve the track directly, otherwise the song is first
+// verified with the metadata repository when one is supplied so the
+// canonical names and ISRC drive the search
+func (ss *screenshotService) search(s *models.Screenshot, song string) (models.Track, string, error) {
+	var (
+		mp    = *ss.musicProvider
+		query = song
+	)
+
+	// shared links and notes may contain the Spotify track itself
+	if id := spotifyTrackID(s.Text); id != "" {
+		if spr, ok := mp.(interfaces.ISpotifyRepository); ok {
+			track, err := spr.GetTrack(id)
+			if err != nil {
+				return models.Track{}, query, err
+			}
+
+			if track != nil {
+				log.Debug().Str("id", id).Msg("resolved Spotify track from link in screenshot")
+				return *track, trackSearchTerm(*track), nil
+			}
+		}
+	}
+
+	// Shazam share cards and notes may contain the ISRC
+	if code := isrcCode(s.Text); code != "" {
+		tracks, err := mp.SearchISRC(code, 1)
+		if err != nil {
+			return models.Track{}, query, err
+		}
+
+		if len(tracks) > 0 {
+			return tracks[0], trackSearchTerm(tracks[0]), nil
+		}
+	}
+
+	return ss.searchSong(&s.Recording, s.SongSearchTerm == song, song)
+}
+
+// searchSong finds the track for the song with the music provider,
+// verifying the song with the metadata repository when one is supplied
+// and the recording wasn't already verified for the same song
+func (ss *screenshotService) searchSong(recording **models.Recording, verified bool, song string) (models.Track, string, error) {
+	var (
+		mp    = *ss.musicProvider
+		query = song
+	)
+
+	if ss.metadataRepository != nil {
+		mdr := *ss.metadataRepository
+
+		// verify again only when the song has changed since the last run
+		if *recording == nil || !verified {
+			rec, err := mdr.FindRecording(song)
+			if err != nil {
+				log.Warn().Str("song", song).Err(err).Msg("unable to verify song")
+			}
+
+			*recording = rec
+		}
+
+		if rec := *recording; rec != nil {
+			query = fmt.Sprintf("%s %s", strings.Join(rec.Artists, " "), rec.Title)
+
+			for _, isrc := range rec.ISRCs {
+				tracks, err := mp.SearchISRC(isrc, 1)
+				if err != nil {
+					return models.Track{}, query, err
+				}
+
+				if len(tracks) > 0 {
+					return tracks[0], query, nil
+				}
+			}
+		}
+	}
+
+	tracks, err := mp.Search(query, 1)
+	if err != nil || len(tracks) == 0 {
+		return models.Track{}, query, err
+	}
+
+	return tracks[0], query, nil
+}
+
+// State returns the persisted state from the most recent run
+func (ss *screenshotService) State() (models.State, error) {
+	state, err := loadState(*ss.stateRepository)
+	if err != nil {
+		return models.State{}, err
 	}
 
 	return *state, nil
 }
 
 // SearchTerm returns a possible artist and
-// and song title match from the annotation
-func (ss *screenshotService) SearchTerm(annotation string) string {
+// and song title match from the annotation, with the confidence that
+// the annotation is a song and the reason for it. The confidence is 0
+// when no source layout or divider was found in the annotation
+func (ss *screenshotService) SearchTerm(annotation string) models.SearchTerm {
 	var (
 		lines     []string
 		songParts []string
 	)
 
-	//fmt.Println(annotation)
+	annotation = foldHomoglyphs(norm.NFC.String(annotation))
 
 	isLinn := srch.MatchString(annotation) && pcm.MatchString(annotation)
 	isPandora := pndra.MatchString(annotation)
@@ -179,7 +462,11 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 	}
 
 	if len(lines) == 1 {
-		return lines[0]
+		if div.MatchString(lines[0]) {
+			return parsed(sanitizeSong(splitArtist(trimIcon(lines[0]))), confidenceDivider, "divider")
+		}
+
+		return unparsed(lines[0])
 	}
 
 	for i, line := range lines {
@@ -190,23 +477,30 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 
 		// when Shazam or Sonos radio, continue until near song artist and name
 		if isShazam || isSonosRadio {
-			if shzm.MatchString(line) || snsrad.MatchString(line) {
+			// the artist and name are on the lines above, which
+			// headings of lists such as "My Shazams" don't have
+			if (shzm.MatchString(line) || snsrad.MatchString(line)) && i >= 2 {
 				log.Debug().Msg("detected a Shazam  or Sonos screenshot")
 				artist := lines[i-1]
 				name := lines[i-2]
 
 				// Shazam wraps multiple artists
-				if name[len(name)-1:] == "&" {
+				if strings.HasSuffix(name, "&") && i >= 3 {
 					artist = fmt.Sprintf("%s %s", name, artist)
 					name = lines[i-3]
 				}
 
 				// Sonos radio has the 3 dots
-				if jnk.MatchString(name) {
+				if jnk.MatchString(name) && i >= 3 {
 					name = lines[i-3]
 				}
 
-				return sanitizeSong(fmt.Sprintf("%s %s", artist, name))
+				reason := "Shazam layout"
+				if isSonosRadio {
+					reason = "Sonos radio layout"
+				}
+
+				return parsed(sanitizeSong(fmt.Sprintf("%s %s", artist, name)), confidenceLayout, reason)
 			}
 
 			continue
@@ -215,21 +509,27 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 		// when Spotify or Pandora, continue until near the song artist and name
 		if isSpotify || isPandora {
 			// check to see if two numbers appear on the same line (scrubber)
-			// and that the song is playing from Spotify
-			if num.MatchString(line) && num.MatchString(lines[i+1]) {
+			// and that the song is playing from Spotify, with the name and
+			// artist on the lines below unless the screenshot is cut off
+			if i+3 < len(lines) && num.MatchString(line) && num.MatchString(lines[i+1]) {
 				log.Debug().Msg("detected a Spotify or Pandora radio screenshot")
 
 				artist := lines[i+3]
 				name := lines[i+2]
 
 				// handle scenarios where the 3 dots is detected in the image
-				if artist == `` || jnk.MatchString(artist) {
+				if (artist == `` || jnk.MatchString(artist)) && i+4 < len(lines) {
 					artist = lines[i+4]
 				}
 
 				// safe to clear everything prior to this point because the
 				// song detail begins below (in fact, the song name is next)
-				return formatSongFromSpotifyOrPandora(artist, name)
+				reason := "Spotify layout"
+				if isPandora {
+					reason = "Pandora layout"
+				}
+
+				return parsed(formatSongFromSpotifyOrPandora(artist, name), confidenceLayout, reason)
 			}
 
 			continue
@@ -237,7 +537,8 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 
 		// when Linn, continue until the PCM line
 		if isLinn {
-			if pcm.MatchString(line) {
+			// the artist and name are above the album and play location
+			if pcm.MatchString(line) && i >= 4 {
 				log.Debug().Msg("detected a Linn screenshot")
 
 				// <artist name - may be multiple lines>
@@ -250,12 +551,12 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 				name := lines[i-3]
 
 				// check for unclosed paranthesis
-				if open.MatchString(name) != close.MatchString(name) {
+				if open.MatchString(name) != close.MatchString(name) && i >= 5 {
 					name = fmt.Sprintf("%s %s", artist, name)
 					artist = lines[i-5]
 				}
 
-				return sanitizeSong(fmt.Sprintf("%s %s", artist, name))
+				return parsed(sanitizeSong(fmt.Sprintf("%s %s", artist, name)), confidenceLayout, "Linn layout")
 			}
 
 			continue
@@ -264,7 +565,7 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 		// if the song divider is present on this line,
 		// return directly
 		if div.MatchString(line) {
-			return sanitizeSong(div.ReplaceAllString(line, "$1 $3"))
+			return parsed(sanitizeSong(splitArtist(trimIcon(line))), confidenceDivider, "divider")
 		}
 
 		// filter out Numbers only
@@ -311,7 +612,132 @@ func (ss *screenshotService) SearchTerm(annotation string) string {
 	}
 
 	// join the artist and song name for search
-	return sanitizeSong(strings.Join(songParts, " "))
+	song := sanitizeSong(strings.Join(songParts, " "))
+
+	// the radio station and lock screen place the song on the lines
+	// that remain once their labels are filtered out
+	switch {
+	case song == "":
+		return unparsed(song)
+	case isPRP:
+		return parsed(song, confidenceRemaining, "Portland Radio Project layout")
+	case swpup.MatchString(annotation):
+		return parsed(song, confidenceRemaining, "lock screen layout")
+	}
+
+	return unparsed(song)
+}
+
+func parsed(song string, confidence float64, reason string) models.SearchTerm {
+	term, hints := normalizeTitle(song)
+
+	return models.SearchTerm{
+		Confidence: confidence,
+		Hints:      hints,
+		Reason:     reason,
+		Term:       term,
+	}
+}
+
+func unparsed(song string) models.SearchTerm {
+	term, hints := normalizeTitle(song)
+
+	return models.SearchTerm{
+		Hints:  hints,
+		Reason: "no source layout or divider",
+		Term:   term,
+	}
+}
+
+func loadState(str interfaces.IStateRepository) (*models.State, error) {
+	state := &models.State{}
+
+	if err := str.Load(state); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		state = &models.State{
+			SoftwareVersion: softwareVersion,
+		}
+	}
+
+	if state.Playlists == nil {
+		state.Playlists = map[string]*models.PlaylistSync{}
+	}
+
+	if state.Screenshots == nil {
+		state.Screenshots = map[string]*models.Screenshot{}
+	}
+
+	for _, s := range state.Screenshots {
+		s.Migrate()
+	}
+
+	return state, nil
+}
+
+func saveState(str interfaces.IStateRepository, state *models.State) {
+	// save the state off for subsequent use
+	if err := str.Save(state); err != nil {
+		log.Error().Stack().Err(err).Msg("unable to save the state")
+	}
+}
+
+// stderrOutput writes the progress bar to stderr so results
+// written to stdout remain machine-readable
+type stderrOutput struct{}
+
+func (o stderrOutput) ClearLine() {
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
+
+func (o stderrOutput) Printf(format string, vals ...interface{}) {
+	o.ClearLine()
+	fmt.Fprintf(os.Stderr, format, vals...)
+}
+
+func newProgressBar(action string, total int) *bar.Bar {
+	return bar.NewWithOpts(
+		bar.WithDimensions(total, total),
+		bar.WithOutput(stderrOutput{}),
+		bar.WithFormat(
+			fmt.Sprintf(
+				" %s%s...%s :percent :bar %s:eta%s     ",
+				chalk.Blue,
+				action,
+				chalk.Reset,
+				chalk.Green,
+				chalk.Reset,
+			),
+		),
+	)
+}
+
+// isrcCode returns the ISRC, without hyphens, labelled in the annotation
+func isrcCode(annotation string) string {
+	m := isrc.FindStringSubmatch(annotation)
+	if m == nil {
+		return ""
+	}
+
+	return strings.ToUpper(strings.ReplaceAll(m[1], "-", ""))
+}
+
+// spotifyTrackID returns the ID of an open.spotify.com track link or
+// spotify:track: URI found in the annotation
+func spotifyTrackID(annotation string) string {
+	m := sptrk.FindStringSubmatch(annotation)
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+// trackSearchTerm returns the artists and name of the track
+func trackSearchTerm(t models.Track) string {
+	return fmt.Sprintf("%s %s", strings.Join(t.Artists, " "), t.Name)
 }
 
 func formatSongFromSpotifyOrPandora(artist string, name string) string {
@@ -324,24 +750,37 @@ func formatSongFromSpotifyOrPandora(artist string, name string) string {
 	return sanitizeSong(fmt.Sprintf("%s %s", artist, name))
 }
 
+// splitArtist replaces the divider between the artist and the title
+// with a space, keeping any later divider before the version details
+// of the title
+func splitArtist(line string) string {
+	loc := div.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return line
+	}
+
+	return line[:loc[4]] + " " + line[loc[5]:]
+}
+
+// trimIcon removes the icon of a player OCR reads as a character set
+// apart from the end of the divided line by a wide gap
+func trimIcon(line string) string {
+	return icn.ReplaceAllString(line, "")
+}
+
 func sanitizeSong(song string) string {
 	// convert to lowercase
 	song = strings.ToLower(song)
 
-	// remove multiple spaces
-	song = strings.ReplaceAll(song, "  ", " ")
+	// remove multiple spaces, including the wide and non-breaking
+	// spaces of other scripts
+	song = strings.Join(strings.Fields(song), " ")
 
 	// swap & with ,
 	song = strings.ReplaceAll(song, " &", ",")
 
-	// remove (feat. XXXX) wording
-	song = feat.ReplaceAllString(song, "")
-
 	// removing leading and trailing space
 	song = strings.TrimSpace(song)
 
-	// remove the divider char (-) if found
-	song = div.ReplaceAllString(song, "")
-
 	return song
 }
@@ -1,386 +1,113 @@
 package services_test
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/brozeph/song-finder/internal/models"
 	"github.com/brozeph/song-finder/internal/services"
 )
 
-var s = services.NewScreenshotService(nil, nil, nil)
-
-func TestSongArtistAndNameFromPRP(t *testing.T) {
-	testAnnotation := `
-11:53
-Master Bedroom + 3
-BP
-Porntland Radis Pryeat
-Portland Radio Project
-Reverend Freakchild - Personal Jesus (On the...
-Portland Radio Project on TUNE
-IN
-K
-») -
-☆
-Мy Sonos
-Browse
-Rooms
-Search
-Settings
-
-`
-	expected := "reverend freakchild personal jesus (on the..."
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromPRP2(t *testing.T) {
-	testAnnotation := `
-3:40 1
-Move + Den
-CD 100%
-BP
-Porntland Radis Pryeat
-Portland Radio Project
-• . .
-Blisses B - Twin Geeks
-Portland Radio Project on TUNE
-IN
-K
->)
-☆
-Мy Sonos
-Browse
-Rooms
-Search
-Settings
-
-`
-	expected := "blisses b twin geeks"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromPRP3(t *testing.T) {
-	testAnnotation := `8:47 1
-Kitchen
-PP
-Pontland Rad9 Projedt
-Portland Radio Project
-•..
-YellowStraps - Goldress (feat. VYNK)
-Portland Radio Project
-IN
-TUNE
-K]
-!!
-`
-	expected := "yellowstraps goldress"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromPRP4(t *testing.T) {
-	testAnnotation := `
-11:55
-レ
-Living Room + 3
-ニ
-BP
-Portland Racfi9 Projedt
-Portland Radio Project
-RAC Pron R.A.C. - This Song Feat Rostam
-Portland Radio Project on TUNE
-IN
-») -
-My Sonos
-Browse
-Rooms
-Search
-Settings
-`
-	expected := "rac pron r.a.c. this song feat rostam"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromPRPLockScreen(t *testing.T) {
-	testAnnotation := `
-Portland Radio Project
-Pontland Radio Prjeat
-The Dig - Soul of the Night
-
-`
-	expected := "the dig soul of the night"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromShazam(t *testing.T) {
-	testAnnotation := `
-SONGWRITER POF
-ou
-orden
-Campe
-cemn
-a Pen
-yougs dhe
-In My Atmosphere
-Raphael Lake & Eric Brooks &
-Camden Rose
-2 7,392 Shazams
-A Spotify
-ОPEN
-ADD TO
-TOP SONGS
-Ready
-Raprerel Lake QAaronLovy &...
-INDIE SOUL
-Lone
-
-`
-	expected := "raphael lake, eric brooks, camden rose in my atmosphere"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromSonosRadio(t *testing.T) {
-	testAnnotation := `
-6:31 1
-Guest Room
-Giddy
-• . .
-Jessy Lanza
-Sunset Fuzz on SONOS Radio
-K
->)
-☆
-Мy Sonos
-Browse
-Rooms
-Search
-Settings
-JESSY LANZA
-Pull my hair back
-
-`
-	expected := "jessy lanza giddy"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
-	}
-}
-
-func TestSongArtistAndNameFromSonosRadio2(t *testing.T) {
-	testAnnotation := `
-6:16 1
-Guest Room
-Obsessed
-• . .
-Hatchie
-Sunset Fuzz on SONOS Radio
-K
-||
->)
-☆
-Мy Sonos
-Browse
-Rooms
-Search
-Settings
-`
-	expected := "hatchie obsessed"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+var s = services.NewScreenshotService(nil, nil, nil, nil, nil)
+
+func TestSearchTermReportsConfidence(t *testing.T) {
+	tests := []struct {
+		annotation string
+		parsed     bool
+		reason     string
+	}{
+		{"SG Lewis\nCHEMICALS\n1:12\n-3:02\nChemicals\n• ..\nSG Lewis • Chemicals\nPlaying from E Spotify\n", true, "Spotify layout"},
+		{"Portland Radio Project\nThe Dig - Soul of the Night\n", true, "divider"},
+		{"Tuesday, February 12\nPnthnt Ruda Pge Smallpools Stumblin' Home\nSwipe up to open\n", true, "lock screen layout"},
+		{"Messages\nAre we still on for dinner tonight\nSee you at 7\n", false, "no source layout or divider"},
 	}
-}
 
-func TestSongArtistAndNameFromSpotifyLockScreen(t *testing.T) {
-	testAnnotation := `
-Tuesday, February 12
-Portland Radio Project
-Pnthnt Ruda Pge Smallpools - Stumblin' Home
-Swipe up to ópen
-`
-	expected := "pnthnt ruda pge smallpools stumblin' home"
-	song := s.SearchTerm(testAnnotation)
+	for _, test := range tests {
+		st := s.SearchTerm(test.annotation)
 
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+		if st.Parsed() != test.parsed || st.Reason != test.reason {
+			t.Errorf("expected %q to be parsed %t by %q: %+v", test.annotation, test.parsed, test.reason, st)
+		}
 	}
 }
 
-func TestSongArtistAndNameFromSpotifyMinimal(t *testing.T) {
-	testAnnotation := `
-SG Lewis
-CHEMICALS
-1:12
--3:02
-Chemicals
-• ..
-SG Lewis • Chemicals
-Playing from E Spotify
-`
-	expected := "sg lewis chemicals"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+func TestSearchTermSeparatesVersionHints(t *testing.T) {
+	tests := []struct {
+		annotation string
+		expected   string
+		hints      models.TitleHints
+	}{
+		{"Depeche Mode - Personal Jesus (On the...", "depeche mode personal jesus", models.TitleHints{Truncated: true}},
+		{"The Beatles - Here Comes the Sun - Remastered 2019", "the beatles here comes the sun", models.TitleHints{Remastered: true}},
+		{"Nirvana - Lake of Fire (Live at MTV Unplugged)", "nirvana lake of fire", models.TitleHints{Live: true}},
+		{"SG Lewis - Chemicals [Radio Edit]", "sg lewis chemicals", models.TitleHints{Edited: true}},
+		{"Calvin Harris - Feels (with Pharrell Williams & Katy Perry)", "calvin harris feels", models.TitleHints{Featured: []string{"pharrell williams", "katy perry"}}},
+		{"Billy Idol - Dancing with Myself", "billy idol dancing with myself", models.TitleHints{}},
+		{"Portugal. The Man - Live in the Moment", "portugal. the man live in the moment", models.TitleHints{}},
+		{"blink-182 - Live on Mars", "blink-182 live on mars", models.TitleHints{}},
+		{"Queen - Bohemian Rhapsody - Live Aid", "queen bohemian rhapsody", models.TitleHints{Live: true}},
+		{"Dance Gavin Dance - The Feat of Strength", "dance gavin dance the feat of strength", models.TitleHints{}},
+		{"Daft Punk - Get Lucky ft. Pharrell Williams", "daft punk get lucky", models.TitleHints{Featured: []string{"pharrell williams"}}},
 	}
-}
-
-func TestSongArtistAndNameFromSpotify(t *testing.T) {
-	testAnnotation := `
-6:46 1
-Kitchen + 2
-ZD 100%
-SONNY ALVEN
-WASTĘD YOUTH (FEAT. CAL)
-AMERIC
-1:11
--2:05
-Wasted Youth
-Sonny Alven• Girls - EP
-feel good
-Spotify
-!!
-%3D
 
-`
-	expected := "sonny alven wasted youth"
-	song := s.SearchTerm(testAnnotation)
+	for _, test := range tests {
+		st := s.SearchTerm(test.annotation)
 
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+		if st.Term != test.expected || !reflect.DeepEqual(st.Hints, test.hints) {
+			t.Errorf("expected %q with hints %+v from %q: %q %+v", test.expected, test.hints, test.annotation, st.Term, st.Hints)
+		}
 	}
 }
 
-func TestSongArtistAndNameFromSpotify2(t *testing.T) {
-	testAnnotation := `
-6:06
-Guest Room + Den + 4
-CD 100%
-ZD)
-TRUE COLORS
-6:55
--0:28
-Раpercut
-• • •
-Zedd • True Colors
-Playing from 6 Spotify
-K |
-») –
-☆
-Мy Sonos
-Browse
-Rooms
-Search
-Settings
-
-`
-	expected := "zedd раpercut"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+func TestSearchTermKeepsWordsInOtherScripts(t *testing.T) {
+	tests := []struct {
+		annotation string
+		expected   string
+	}{
+		{"Кино - Группа крови\n", "кино группа крови"},
+		{"Perfume - Spring of Life ね\n", "perfume spring of life ね"},
+		{"Kenshi Yonezu - Lemon レ", "kenshi yonezu lemon レ"},
+		// the icon a wide gap sets apart from the title is not a word
+		{"米津玄師 - Lemon\u3000ポ", "米津玄師 lemon"},
+		{"米津玄師 - Lemon  ポ\n", "米津玄師 lemon"},
 	}
-}
 
-func TestSongArtistAndNameFromPandora(t *testing.T) {
-	testAnnotation := `
-8:32 1
-Kitchen + 2
-ZD 100%
-A FINE FRENZY
-ONE CELL IN THE SEA
-2:22
--1:54
-Hope For The Hopeless
-A Fine Frenzy • One Cell In The Sea
-Rosi Golan Radio (My Station)
-pandora
-K]
-!!
-%3D
-
-`
-	expected := "a fine frenzy hope for the hopeless"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+	for _, test := range tests {
+		if song := s.SearchTerm(test.annotation).Term; song != test.expected {
+			t.Errorf("expected %q from %q: %q", test.expected, test.annotation, song)
+		}
 	}
 }
 
-func TestSongArtistAndNameFromLinn(t *testing.T) {
-	testAnnotation := `
-Search
-KYDD
-Walk On You
-NO
-WALKING
-Кydd
-Walk On You
-Walk On You
-1:16
-PCM 44.1 kHz/16 bit 1.4 Mbps
-4:30
-K ||
-44
-
-`
-	expected := "кydd walk on you"
-	song := s.SearchTerm(testAnnotation)
-
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+func TestSearchTermHandlesCutOffLayouts(t *testing.T) {
+	// screenshots cut off before the lines the layout of each source
+	// places the song on
+	tests := []struct {
+		source     string
+		annotation string
+		parsed     bool
+	}{
+		{"Shazam", "My Shazams\nSong\n", false},
+		{"Shazam", "Shazams\nfoo\nbar\n", false},
+		{"Shazam", "Artist &\nShazam\n", false},
+		{"Sonos radio", "on Sonos Radio\n", false},
+		{"Sonos radio", "...\nArtist\non Sonos Radio\n", true},
+		{"Spotify", "Listen on Spotify\n1:23\n2:34\n", false},
+		{"Spotify", "Shared from Spotify\n12\n", false},
+		{"Spotify", "Playing from Spotify\n1:12\n-3:02\nChemicals\n• ..\n", true},
+		{"Pandora", "Pandora\n1\n2\nfoo\n", true},
+		{"Linn", "Search\nPCM 44.1 kHz\n", false},
+		{"Linn", "Search\nAlbum\n1:16\nPCM 44.1 kHz\n", false},
+		{"Linn", "Search\nPersonal Jesus (On the\nAlbum\n1:16\nPCM 44.1 kHz\n", true},
 	}
-}
-
-func TestSongArtistAndNameFromLinn2(t *testing.T) {
-	testAnnotation := `
-Search
-DUSTY
-LEIGH
-NIGHT
-PARENTAL
-ADVISORY
-EXPLICIT CONTENT
-Dusty Leigh
-No Phucks (feat. Bubba
-Sparxxx & Fishscales)
-Boujee Nights
-0:33
-PCM 44.1 kHz/16 bit 1.4 Mbps
-3:17
-|L
-46
 
-`
-	expected := "dusty leigh no phucks (feat. bubba sparxxx, fishscales)"
-	song := s.SearchTerm(testAnnotation)
+	for _, test := range tests {
+		if st := s.SearchTerm(test.annotation); st.Parsed() != test.parsed {
+			t.Errorf("expected the cut off %s layout %q to be parsed %t: %+v", test.source, test.annotation, test.parsed, st)
+		}
 
-	if song != expected {
-		t.Errorf("expected song result \"%s\" from annotation was not matched: \"%s\"", expected, song)
+		if sts := s.SearchTerms(test.annotation); len(sts) != 1 || sts[0].Parsed() != test.parsed {
+			t.Errorf("expected the cut off %s layout %q to be parsed %t: %+v", test.source, test.annotation, test.parsed, sts)
+		}
 	}
 }
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/unicode/norm"
+)
+
+// confidence that each song of a list is a song, lower than that of
+// a single song as rows of a list are more easily misread
+const confidenceList = 0.6
+
+var (
+	ago    = regexp.MustCompile(`(?i)^(\d+\s*(s|sec|m|min|h|hr|hour|d|day|w|week)s?\s+ago|(hace|vor|il y a)\s+\d+\s*\p{L}+|yesterday|today|ayer|hoy|gestern|heute|hier|aujourd'hui)$`)
+	all    = regexp.MustCompile(`(?i)^((see|show|view) all|ver todo|alle anzeigen|tout afficher|voir tout)$`)
+	clock  = regexp.MustCompile(`(?i)^\d{1,2}:\d{2}(\s*[ap]\.?m\.?)?$`)
+	kind   = regexp.MustCompile(`(?i)^(song|single|ep|album|canción|sencillo|titel|titre|brano)\s*•\s*`)
+	lstHdr = regexp.MustCompile(`(?im)^\s*(recently played|recent shazams|my shazams|shazam library|recent tracks|played (songs|tracks)|song history|last played|reproducido recientemente|escuchado recientemente|zuletzt gespielt|écoutés récemment|ascoltati di recente)\s*$`)
+)
+
+// SearchTerms returns each of the songs listed in the annotation when
+// it is a history or library of songs, otherwise the single song
+// returned by SearchTerm
+func (ss *screenshotService) SearchTerms(annotation string) []models.SearchTerm {
+	annotation = foldHomoglyphs(norm.NFC.String(annotation))
+
+	if loc := lstHdr.FindStringIndex(annotation); loc != nil {
+		header := strings.ToLower(strings.TrimSpace(annotation[loc[0]:loc[1]]))
+		if songs := listSongs(annotation[loc[1]:], header); len(songs) > 1 {
+			log.Debug().Str("list", header).Int("songs", len(songs)).Msg("detected a list of songs")
+			return songs
+		}
+	}
+
+	st := ss.SearchTerm(annotation)
+
+	// a radio station's playlist lists a song on each line with the
+	// divider between artist and title
+	if st.Reason == "divider" {
+		if songs := dividerSongs(annotation); len(songs) > 1 {
+			log.Debug().Int("songs", len(songs)).Msg("detected a list of songs")
+			return songs
+		}
+	}
+
+	return []models.SearchTerm{st}
+}
+
+// dividerSongs returns a song for each line of the annotation with the
+// divider between artist and title
+func dividerSongs(annotation string) []models.SearchTerm {
+	var songs []models.SearchTerm
+
+	for _, line := range cr.Split(annotation, -1) {
+		if !div.MatchString(line) || prp.MatchString(line) || sns.MatchString(line) {
+			continue
+		}
+
+		songs = append(songs, parsed(
+			sanitizeSong(splitArtist(line)),
+			confidenceList,
+			"divider list"))
+	}
+
+	return songs
+}
+
+// listSongs returns the songs listed below the header of a history or
+// library, where each row is the title followed by the artist unless
+// the row has the divider between them
+func listSongs(annotation string, header string) []models.SearchTerm {
+	var (
+		rows   []string
+		songs  []models.SearchTerm
+		reason = fmt.Sprintf("%s list", header)
+	)
+
+	for _, line := range cr.Split(annotation, -1) {
+		line = strings.TrimSpace(line)
+
+		// filter out blank lines, times, buttons and labels
+		if line == "" ||
+			num.MatchString(line) ||
+			clock.MatchString(line) ||
+			ago.MatchString(line) ||
+			all.MatchString(line) ||
+			ply.MatchString(line) ||
+			shzm.MatchString(line) ||
+			!wd.MatchString(line) {
+			continue
+		}
+
+		rows = append(rows, kind.ReplaceAllString(line, ""))
+	}
+
+	for i := 0; i < len(rows); i++ {
+		if div.MatchString(rows[i]) {
+			songs = append(songs, parsed(sanitizeSong(splitArtist(rows[i])), confidenceList, reason))
+			continue
+		}
+
+		if i+1 == len(rows) {
+			break
+		}
+
+		songs = append(songs, parsed(sanitizeSong(fmt.Sprintf("%s %s", rows[i+1], rows[i])), confidenceList, reason))
+		i++
+	}
+
+	return songs
+}
+
+// matchSongs searches the music provider for each of the songs listed
+// in the screenshot, the first matched song becomes the track of the
+// screenshot
+func (ss *screenshotService) matchSongs(s *models.Screenshot, terms []models.SearchTerm) error {
+	previous := map[string]*models.Recording{}
+	for _, song := range s.Songs {
+		previous[song.SearchTerm] = song.Recording
+	}
+
+	s.Confidence = 0
+	s.SearchReason = terms[0].Reason
+	s.SongSearchTerm = ""
+	s.Songs = make([]models.Song, 0, len(terms))
+	s.Status = models.StatusParsed
+	s.Track = models.Track{}
+
+	for _, st := range terms {
+		song := models.Song{
+			Reason:     st.Reason,
+			Recording:  previous[st.Term],
+			SearchTerm: st.Term,
+			Status:     models.StatusUnmatched,
+		}
+
+		_, verified := previous[st.Term]
+		track, query, err := ss.searchSong(&song.Recording, verified, st.Term)
+		if err != nil {
+			return err
+		}
+
+		song.Confidence = matchConfidence(query, track, st.Hints)
+		song.Track = track
+
+		if track.URI != "" {
+			song.Status = models.StatusMatched
+
+			if s.Track.URI == "" {
+				s.Confidence = song.Confidence
+				s.SongSearchTerm = song.SearchTerm
+				s.Status = models.StatusMatched
+				s.Track = track
+			}
+		}
+
+		s.Songs = append(s.Songs, song)
+	}
+
+	if s.Track.URI == "" {
+		s.Status = models.StatusUnmatched
+	}
+
+	s.LastSearched = time.Now()
+
+	return nil
+}
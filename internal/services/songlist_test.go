@@ -0,0 +1,113 @@
+package services_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brozeph/song-finder/internal/interfaces"
+	"github.com/brozeph/song-finder/internal/models"
+	"github.com/brozeph/song-finder/internal/services"
+)
+
+func searchTerms(terms []models.SearchTerm) []string {
+	songs := make([]string, 0, len(terms))
+	for _, st := range terms {
+		songs = append(songs, st.Term)
+	}
+
+	return songs
+}
+
+func TestSearchTermsFromShazamLibrary(t *testing.T) {
+	testAnnotation := `
+9:41
+Library
+Recent Shazams
+See All
+Chemicals
+SG Lewis
+2 days ago
+Stumblin' Home
+Smallpools
+Yesterday
+`
+	expected := []string{"sg lewis chemicals", "smallpools stumblin' home"}
+	songs := searchTerms(s.SearchTerms(testAnnotation))
+
+	if !reflect.DeepEqual(songs, expected) {
+		t.Errorf("expected songs %q from annotation were not matched: %q", expected, songs)
+	}
+}
+
+func TestSearchTermsFromSpotifyRecentlyPlayed(t *testing.T) {
+	testAnnotation := `
+Recently played
+Chemicals
+Song • SG Lewis
+Wasted Youth (feat. Cal)
+Song • Sonny Alven
+`
+	expected := []string{"sg lewis chemicals", "sonny alven wasted youth"}
+	terms := s.SearchTerms(testAnnotation)
+	songs := searchTerms(terms)
+
+	if !reflect.DeepEqual(songs, expected) {
+		t.Errorf("expected songs %q from annotation were not matched: %q", expected, songs)
+	}
+
+	if terms[0].Reason != "recently played list" || !terms[0].Parsed() {
+		t.Errorf("expected the songs to be parsed from the list: %+v", terms[0])
+	}
+}
+
+func TestSearchTermsFromRadioPlaylist(t *testing.T) {
+	testAnnotation := `
+Portland Radio Project
+10:42 PM
+The Dig - Soul of the Night
+10:38 PM
+Smallpools - Stumblin' Home
+`
+	expected := []string{"the dig soul of the night", "smallpools stumblin' home"}
+	songs := searchTerms(s.SearchTerms(testAnnotation))
+
+	if !reflect.DeepEqual(songs, expected) {
+		t.Errorf("expected songs %q from annotation were not matched: %q", expected, songs)
+	}
+}
+
+func TestSearchTermsFromSingleSong(t *testing.T) {
+	songs := searchTerms(s.SearchTerms("Portland Radio Project\nThe Dig - Soul of the Night\n"))
+
+	if len(songs) != 1 || songs[0] != "the dig soul of the night" {
+		t.Errorf("expected a single song from annotation: %q", songs)
+	}
+}
+
+func TestMatchSearchesEachListedSong(t *testing.T) {
+	var (
+		dig                                = models.Track{Artists: []string{"The Dig"}, Name: "Soul of the Night", URI: "spotify:track:dig"}
+		sp                                 = models.Track{Artists: []string{"Smallpools"}, Name: "Stumblin' Home", URI: "spotify:track:sp"}
+		mp                                 = &fakeSpotifyRepository{catalog: []models.Track{dig, sp}}
+		provider interfaces.IMusicProvider = mp
+		str                                = newMatchState(t, "Recently Played\nThe Dig - Soul of the Night\nSmallpools - Stumblin' Home\nUnknown - Untitled\n")
+	)
+
+	state, err := services.NewScreenshotService(nil, &provider, &str, nil, nil).Match(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := state.Screenshots["a"]
+	if len(s.Songs) != 3 || s.Songs[2].Status != models.StatusUnmatched {
+		t.Fatalf("expected a song for each row of the list: %+v", s.Songs)
+	}
+
+	if s.Status != models.StatusMatched || s.Track.URI != dig.URI {
+		t.Errorf("expected the first matched song to be the track of the screenshot: %+v", s)
+	}
+
+	if tracks := s.Tracks(); !reflect.DeepEqual(tracks, []models.Track{dig, sp}) {
+		t.Errorf("expected the tracks of each matched song: %+v", tracks)
+	}
+}
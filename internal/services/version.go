@@ -1,3 +1,3 @@
 package services
 
-const softwareVersion = "v1.0.0"
+const softwareVersion = "v1.2.0"